@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// adminOnly guards administrative endpoints with the ADMIN_TOKEN bearer
+// token. Without a configured token the endpoints are disabled entirely.
+func adminOnly(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			http.Error(w, "admin endpoints are disabled, set ADMIN_TOKEN to enable them", http.StatusForbidden)
+			return
+		}
+		provided, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// basicAuth protects the metrics and landing page with the bcrypt hashed
+// passwords of BASIC_AUTH_USERS, so document IDs and user names aren't
+// exposed to anyone who can reach the port. Without users it does nothing.
+func basicAuth(users map[string]string, next http.Handler) http.Handler {
+	if len(users) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		hash, known := users[user]
+		if !known {
+			hash = string(dummyHash())
+		}
+		if !ok || bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil || !known {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Outline Wiki Exporter"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// dummyHash is checked for unknown users so the response time doesn't reveal
+// which user names exist.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("outline-exporter"), bcrypt.DefaultCost)
+	return hash
+})
+
+func handleInvalidate(exporters func() []*Exporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		for _, exporter := range exporters() {
+			exporter.invalidate()
+		}
+		slog.Info("Cached state invalidated", "remote_addr", r.RemoteAddr)
+		w.Write([]byte("OK"))
+	}
+}
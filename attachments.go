@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+)
+
+// Outline has no endpoint listing attachments, so they are found through the
+// links documents embed for uploaded images and files.
+var attachmentRef = regexp.MustCompile(`/api/attachments\.redirect\?id=([0-9a-fA-F-]{36})`)
+
+// maxAttachmentLookups bounds the concurrent requests made to size
+// attachments.
+const maxAttachmentLookups = 4
+
+// attachmentIDs returns the de-duplicated attachments referenced in a text.
+func attachmentIDs(text string) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, match := range attachmentRef.FindAllStringSubmatch(text, -1) {
+		id := strings.ToLower(match[1])
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// sizeAttachments looks up the size of every attachment referenced by the
+// documents that hasn't been sized yet. Attachments are immutable, so each one
+// is only requested once per exporter lifetime. Failed lookups are retried on
+// the next scrape.
+func (e *Exporter) sizeAttachments(ctx context.Context, documents []Document) {
+	e.mu.Lock()
+	var pending []string
+	queued := make(map[string]bool)
+	for _, document := range documents {
+		for _, id := range attachmentIDs(document.Text) {
+			if _, known := e.attachmentSizes[id]; !known && !queued[id] {
+				queued[id] = true
+				pending = append(pending, id)
+			}
+		}
+	}
+	e.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+	e.logger.Debug("Sizing attachments", "count", len(pending))
+
+	var g errgroup.Group
+	g.SetLimit(maxAttachmentLookups)
+	for _, id := range pending {
+		g.Go(func() error {
+			size, err := e.attachmentSize(ctx, id)
+			if err != nil {
+				e.logger.Warn("Error sizing attachment", "attachment", id, "error", err)
+				return nil
+			}
+			e.mu.Lock()
+			e.attachmentSizes[id] = size
+			e.mu.Unlock()
+			return nil
+		})
+	}
+	g.Wait()
+}
+
+// attachmentSize requests the first byte of an attachment through its
+// redirect, which leads to the storage backend, and reads the total size
+// from the response headers without downloading the file.
+func (e *Exporter) attachmentSize(ctx context.Context, id string) (int64, error) {
+	if err := e.limiter.wait(ctx); err != nil {
+		return 0, err
+	}
+	key, label := e.keys.pick()
+	size, err := e.attachmentSizeWithKey(ctx, id, key)
+	e.recordKeyUsage(label, err)
+	return size, err
+}
+
+func (e *Exporter) attachmentSizeWithKey(ctx context.Context, id, key string) (int64, error) {
+	fullURL := e.config.OutlineAPIURL + "/api/attachments.redirect?id=" + url.QueryEscape(id)
+
+	req, err := http.NewRequestWithContext(e.traceConnections(ctx), "GET", fullURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("new request: %w", err)
+	}
+	// The Go client drops the header when redirected to another host, so
+	// the key isn't leaked to the storage backend.
+	req.Header.Set("Authorization", "Bearer "+key)
+	req.Header.Set("Range", "bytes=0-0")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		_, total, found := strings.Cut(resp.Header.Get("Content-Range"), "/")
+		size, err := strconv.ParseInt(total, 10, 64)
+		if !found || err != nil {
+			return 0, fmt.Errorf("unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+		}
+		return size, nil
+	case http.StatusOK:
+		if resp.ContentLength < 0 {
+			return 0, fmt.Errorf("no Content-Length in response")
+		}
+		return resp.ContentLength, nil
+	default:
+		return 0, &apiError{StatusCode: resp.StatusCode}
+	}
+}
+
+func (e *Exporter) collectAttachments(ch chan<- prometheus.Metric, snap *snapshot) {
+	all := make(map[string]bool)
+	perCollection := make(map[string]map[string]bool)
+	for _, document := range snap.documents {
+		for _, id := range attachmentIDs(document.Text) {
+			all[id] = true
+			if perCollection[document.CollectionId] == nil {
+				perCollection[document.CollectionId] = make(map[string]bool)
+			}
+			perCollection[document.CollectionId][id] = true
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(e.attachmentsTotal, prometheus.GaugeValue, float64(len(all)))
+	collectionNames := snap.collectionNames()
+	for collectionID, ids := range perCollection {
+		ch <- prometheus.MustNewConstMetric(e.collectionAttachments, prometheus.GaugeValue,
+			float64(len(ids)), collectionID, collectionNames[collectionID])
+	}
+
+	if !e.config.AttachmentSizes {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	sum := func(ids map[string]bool) float64 {
+		var total int64
+		for id := range ids {
+			total += e.attachmentSizes[id]
+		}
+		return float64(total)
+	}
+	ch <- prometheus.MustNewConstMetric(e.attachmentsBytes, prometheus.GaugeValue, sum(all))
+	for collectionID, ids := range perCollection {
+		ch <- prometheus.MustNewConstMetric(e.collectionAttachmentsBytes, prometheus.GaugeValue,
+			sum(ids), collectionID, collectionNames[collectionID])
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var errCircuitOpen = errors.New("circuit breaker open, Outline API requests suspended")
+
+var circuitStates = []string{"closed", "open", "half_open"}
+
+// circuitBreaker stops sending requests to Outline once
+// CIRCUIT_BREAKER_FAILURES requests in a row failed because it is down, so
+// scrapes fail fast instead of piling up timed-out requests. After
+// CIRCUIT_BREAKER_COOLDOWN a single trial request is let through: its
+// success closes the breaker, its failure opens it again.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	// trial is set while the half-open trial request is in flight.
+	trial bool
+}
+
+// newCircuitBreaker returns nil, which always allows requests, when
+// CIRCUIT_BREAKER_FAILURES is 0.
+func newCircuitBreaker(config Config) *circuitBreaker {
+	if config.CircuitBreakerFailures <= 0 {
+		return nil
+	}
+	return &circuitBreaker{threshold: config.CircuitBreakerFailures, cooldown: config.CircuitBreakerCooldown}
+}
+
+// allow reports whether a request may be sent. In the half-open state only
+// the trial request is, and it must be released once it completed; concurrent
+// requests fail fast until it succeeded.
+func (b *circuitBreaker) allow() (allowed, trial bool) {
+	if b == nil {
+		return true, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.current() {
+	case "closed":
+		return true, false
+	case "half_open":
+		if b.trial {
+			return false, false
+		}
+		b.trial = true
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+// release lets the next trial request through after the previous one
+// completed without closing the breaker, e.g. because it was cancelled.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+func (b *circuitBreaker) state() string {
+	if b == nil {
+		return "closed"
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.current()
+}
+
+// current returns the state. Must be called with b.mu held.
+func (b *circuitBreaker) current() string {
+	switch {
+	case b.failures < b.threshold:
+		return "closed"
+	case time.Since(b.openedAt) < b.cooldown:
+		return "open"
+	default:
+		return "half_open"
+	}
+}
+
+// record updates the breaker with the outcome of a request. Only errors
+// meaning Outline is unreachable or failing count, a 404 or 429 shows it is
+// up.
+func (b *circuitBreaker) record(err error) {
+	if b == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !outage(err) {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = time.Now()
+	}
+}
+
+func outage(err error) bool {
+	if err == nil {
+		return false
+	}
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
+
+func (e *Exporter) collectCircuitBreaker(ch chan<- prometheus.Metric) {
+	current := e.breaker.state()
+	for _, state := range circuitStates {
+		ch <- prometheus.MustNewConstMetric(e.circuitBreakerState, prometheus.GaugeValue, boolValue(state == current), state)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// refreshLoop fetches a new snapshot every SCRAPE_INTERVAL so /metrics can
+// be served from the latest one instead of walking the whole API on every
+// Prometheus scrape.
+func (e *Exporter) refreshLoop() {
+	e.logger.Info("Refreshing Outline data in the background", "interval", e.config.ScrapeInterval)
+	e.refresh(e.ctx)
+	ticker := time.NewTicker(e.config.ScrapeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.refresh(e.ctx)
+		case <-e.ctx.Done():
+			return
+		}
+	}
+}
+
+func (e *Exporter) refresh(ctx context.Context) *snapshot {
+	ctx, span := tracing.start(ctx, "scrape", spanKindInternal, "outline.instance", e.config.InstanceName)
+	snap := e.scrape(ctx)
+	if ctx.Err() != nil {
+		span.end(ctx.Err())
+	} else if !snap.ok() {
+		span.end(fmt.Errorf("%d resources failed", len(snap.failed)))
+	} else {
+		span.end(nil)
+	}
+	// A scrape cut short because Prometheus went away is incomplete: it
+	// neither replaces the latest snapshot nor counts as changes.
+	if ctx.Err() != nil {
+		e.logger.Warn("Scrape cancelled before it completed", "error", ctx.Err())
+		return snap
+	}
+	e.detectClockSkew(snap)
+	e.trackChanges(snap)
+
+	e.mu.Lock()
+	e.latest = snap
+	e.mu.Unlock()
+	e.readyOnce.Do(func() { close(e.ready) })
+	return snap
+}
+
+// current returns the snapshot to build metrics from. Without a scrape
+// interval every call fetches a fresh one, unless CACHE_TTL allows reusing
+// the latest; otherwise the latest background snapshot is returned, waiting
+// for the first one after startup until ctx is done.
+func (e *Exporter) current(ctx context.Context) *snapshot {
+	if e.config.ScrapeInterval <= 0 {
+		if e.config.CacheTTL > 0 {
+			return e.cached(ctx)
+		}
+		return e.refresh(ctx)
+	}
+
+	select {
+	case <-e.ready:
+	case <-ctx.Done():
+		return &snapshot{pending: true, takenAt: time.Now()}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.latest
+}
+
+// cached returns the latest snapshot while it is younger than CACHE_TTL.
+// Past it and within CACHE_STALE_WHILE_REVALIDATE the latest snapshot is
+// still returned while a refresh runs in the background; older snapshots
+// are refreshed before returning. Scrapes arriving during a refresh wait for
+// it instead of starting their own.
+func (e *Exporter) cached(ctx context.Context) *snapshot {
+	latest := e.latestSnapshot()
+	if latest != nil {
+		age := time.Since(latest.takenAt)
+		if age < e.config.CacheTTL {
+			return latest
+		}
+		if age < e.config.CacheTTL+e.config.CacheStaleTTL {
+			if e.refreshMu.TryLock() {
+				// The refresh outlives the scrape that started it.
+				go func() {
+					defer e.refreshMu.Unlock()
+					e.refresh(e.ctx)
+				}()
+			}
+			return latest
+		}
+	}
+
+	e.refreshMu.Lock()
+	defer e.refreshMu.Unlock()
+	if latest := e.latestSnapshot(); latest != nil && time.Since(latest.takenAt) < e.config.CacheTTL {
+		return latest
+	}
+	return e.refresh(ctx)
+}
+
+func (e *Exporter) latestSnapshot() *snapshot {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.latest
+}
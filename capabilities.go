@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectorEndpoints maps every collector to the API endpoint it depends on
+// and the request used to probe it.
+var collectorEndpoints = []struct {
+	name     string
+	endpoint string
+	probe    map[string]any
+}{
+	{"team", "/api/auth.info", map[string]any{}},
+	{"collections", "/api/collections.list", map[string]any{"limit": 1}},
+	// Memberships are listed per collection and the endpoint can't be
+	// probed without one, so the collection list stands in for it.
+	{"memberships", "/api/collections.list", map[string]any{"limit": 1}},
+	{"documents", "/api/documents.list", map[string]any{"limit": 1}},
+	{"drafts", "/api/documents.list", map[string]any{"limit": 1, "statusFilter": []string{"draft"}}},
+	{"archived", "/api/documents.archived", map[string]any{"limit": 1}},
+	{"deleted", "/api/documents.deleted", map[string]any{"limit": 1}},
+	{"templates", "/api/documents.list", map[string]any{"limit": 1, "template": true}},
+	{"users", "/api/users.list", map[string]any{"limit": 1}},
+	{"user_states", "/api/users.list", map[string]any{"limit": 1, "filter": "suspended"}},
+	{"comments", "/api/comments.list", map[string]any{"limit": 1}},
+	{"searches", "/api/documents.search", map[string]any{"limit": 1, "query": "outline"}},
+	{"groups", "/api/groups.list", map[string]any{"limit": 1}},
+	{"shares", "/api/shares.list", map[string]any{"limit": 1}},
+	{"events", "/api/events.list", map[string]any{"limit": 1}},
+	{"stars", "/api/stars.list", map[string]any{"limit": 1}},
+	{"file_operations", "/api/fileOperations.list", map[string]any{"limit": 1, "type": "export"}},
+}
+
+// watchCapabilities probes the instance at startup, unless the preflight
+// check already did, and then periodically. Older and Cloud versions of
+// Outline don't serve every endpoint, and a missing one would otherwise fail
+// every single scrape.
+func (e *Exporter) watchCapabilities(probeNow bool) {
+	if probeNow {
+		e.detectCapabilities()
+	}
+	if e.config.CapabilityCheckInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(e.config.CapabilityCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.detectCapabilities()
+		case <-e.ctx.Done():
+			return
+		}
+	}
+}
+
+// authFailureThreshold is the number of consecutive 401/403 responses after
+// which a collector is disabled. A single one can be a hiccup, a streak means
+// the API key lacks the scope.
+const authFailureThreshold = 3
+
+type collectorStatus struct {
+	supported    bool
+	authFailures int
+	// reason explains why the collector is disabled, empty when enabled.
+	reason string
+}
+
+func (e *Exporter) detectCapabilities() {
+	for _, collector := range collectorEndpoints {
+		if !e.config.Collectors[collector.name] {
+			continue
+		}
+		// Only the status matters, some endpoints wrap their data in an object.
+		var response struct {
+			Data json.RawMessage `json:"data"`
+		}
+		err := e.fetch(e.ctx, collector.endpoint, &response, collector.probe)
+
+		var apiErr *apiError
+		switch {
+		case err == nil:
+			e.setSupported(collector.name, true)
+			e.recordResult(collector.name, nil)
+		case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+			e.setSupported(collector.name, false)
+		default:
+			// Transient failures say nothing about the endpoint, keep the
+			// previous verdict and let the scrape report the error.
+			e.logger.Debug("Capability probe failed", "collector", collector.name, "error", err)
+			e.recordResult(collector.name, err)
+		}
+	}
+}
+
+// status returns the status of a collector, creating it on first use. Must
+// be called with e.mu held.
+func (e *Exporter) status(collector string) *collectorStatus {
+	if e.collectors == nil {
+		e.collectors = make(map[string]*collectorStatus)
+	}
+	status, ok := e.collectors[collector]
+	if !ok {
+		status = &collectorStatus{supported: true}
+		if !e.config.Collectors[collector] {
+			status.reason = "disabled"
+		}
+		e.collectors[collector] = status
+	}
+	return status
+}
+
+func (e *Exporter) setSupported(collector string, supported bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	status := e.status(collector)
+	if status.supported == supported {
+		return
+	}
+	status.supported = supported
+	if supported {
+		if status.reason == "unsupported" {
+			status.reason = ""
+		}
+		e.logger.Info("Collector enabled, endpoint is available", "collector", collector)
+	} else {
+		if status.reason == "" {
+			status.reason = "unsupported"
+		}
+		e.logger.Warn("Collector disabled, endpoint is not available on this Outline instance", "collector", collector)
+	}
+}
+
+// recordResult tracks authorization failures of a collector's requests and
+// disables the collector when the API key keeps being rejected, so that it
+// stops sending requests bound to fail. A later success, e.g. a capability
+// probe after the key was granted more scopes, enables it again. Collectors
+// are never disabled while every one of them is rejected.
+func (e *Exporter) recordResult(collector string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	status := e.status(collector)
+
+	var apiErr *apiError
+	if err == nil || !errors.As(err, &apiErr) ||
+		(apiErr.StatusCode != http.StatusUnauthorized && apiErr.StatusCode != http.StatusForbidden) {
+		if err == nil && (status.reason == "unauthorized" || status.reason == "forbidden") {
+			e.logger.Info("Collector enabled, API key is authorized again", "collector", collector)
+			status.reason = ""
+		}
+		if err == nil {
+			status.authFailures = 0
+		}
+		return
+	}
+
+	status.authFailures++
+	if status.authFailures >= authFailureThreshold && status.reason == "" {
+		if e.everyCollectorRejected() {
+			// A key rejected by every endpoint is expired or revoked, not
+			// missing a scope: keep the collectors failing so outline_up
+			// reports it.
+			return
+		}
+		status.reason = "unauthorized"
+		if apiErr.StatusCode == http.StatusForbidden {
+			status.reason = "forbidden"
+		}
+		e.logger.Warn("Collector disabled after consecutive authorization failures, check the API key scopes",
+			"collector", collector, "failures", status.authFailures, "status", apiErr.StatusCode)
+	}
+}
+
+// everyCollectorRejected reports whether the last requests of every enabled
+// and supported collector were rejected. Must be called with e.mu held.
+func (e *Exporter) everyCollectorRejected() bool {
+	for _, collector := range collectorEndpoints {
+		status := e.status(collector.name)
+		if e.config.Collectors[collector.name] && status.supported && status.authFailures == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// collectorActive reports whether a collector should run. Collectors are
+// assumed to be supported and authorized until proven otherwise.
+func (e *Exporter) collectorActive(collector string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.status(collector).reason == ""
+}
+
+// collectorRejected reports whether a collector was disabled because the API
+// key is rejected. Its data is missing, so it still counts as failed.
+func (e *Exporter) collectorRejected(collector string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	reason := e.status(collector).reason
+	return reason == "unauthorized" || reason == "forbidden"
+}
+
+func (e *Exporter) collectCapabilities(ch chan<- prometheus.Metric) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, collector := range collectorEndpoints {
+		status := e.status(collector.name)
+		supported, enabled := 0.0, 0.0
+		if status.supported {
+			supported = 1
+		}
+		if status.reason == "" {
+			enabled = 1
+		}
+		ch <- prometheus.MustNewConstMetric(e.collectorSupported, prometheus.GaugeValue, supported, collector.name)
+		ch <- prometheus.MustNewConstMetric(e.collectorEnabled, prometheus.GaugeValue, enabled, collector.name, status.reason)
+	}
+}
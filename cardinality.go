@@ -0,0 +1,82 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// topDocumentRankings orders documents for DOCUMENT_TOP_N_BY, highest first.
+var topDocumentRankings = map[string]func(a, b Document) bool{
+	"views":   func(a, b Document) bool { return a.Views > b.Views },
+	"size":    func(a, b Document) bool { return a.size() > b.size() },
+	"updated": func(a, b Document) bool { return a.UpdatedAt.After(b.UpdatedAt) },
+}
+
+// detailedDocuments returns the keys of the documents that get per-document
+// series. The remaining documents are only exported through per-collection
+// aggregates, which keeps the series count bounded on large, old wikis.
+func (e *Exporter) detailedDocuments(documents map[string]Document) map[string]bool {
+	detailed := make(map[string]bool, len(documents))
+	if !e.config.PerDocumentMetrics {
+		return detailed
+	}
+
+	window := e.config.DocumentActivityWindow
+	var cutoff time.Time
+	if window > 0 {
+		cutoff = e.cutoff(window)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for key, document := range documents {
+		if window > 0 && document.UpdatedAt.Before(cutoff) && e.lastViewed[document.ID].Before(cutoff) {
+			continue
+		}
+		detailed[key] = true
+	}
+	if e.config.DocumentTopN > 0 {
+		detailed = e.topDocuments(documents, detailed)
+	}
+	return detailed
+}
+
+// detailedDocumentIDs is detailedDocuments for a list of documents, keyed by
+// document ID, for the per-document series of other collectors.
+func (e *Exporter) detailedDocumentIDs(documents []Document) map[string]bool {
+	byKey := make(map[string]Document, len(documents))
+	for _, document := range documents {
+		byKey[document.ID+":"+document.CollectionId] = document
+	}
+	ids := make(map[string]bool)
+	for key := range e.detailedDocuments(byKey) {
+		ids[byKey[key].ID] = true
+	}
+	return ids
+}
+
+// topDocuments narrows the detailed documents down to the DOCUMENT_TOP_N
+// first by each ranking of DOCUMENT_TOP_N_BY. A document ranking high by any
+// of them is kept.
+func (e *Exporter) topDocuments(documents map[string]Document, candidates map[string]bool) map[string]bool {
+	keys := make([]string, 0, len(candidates))
+	for key := range candidates {
+		keys = append(keys, key)
+	}
+
+	top := make(map[string]bool)
+	for _, ranking := range e.config.DocumentTopNBy {
+		less := topDocumentRankings[ranking]
+		sort.Slice(keys, func(i, j int) bool {
+			a, b := documents[keys[i]], documents[keys[j]]
+			if less(a, b) != less(b, a) {
+				return less(a, b)
+			}
+			return keys[i] < keys[j]
+		})
+		for _, key := range keys[:min(e.config.DocumentTopN, len(keys))] {
+			top[key] = true
+		}
+	}
+	return top
+}
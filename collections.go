@@ -0,0 +1,26 @@
+package main
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// private reports whether only members can access the collection. Recent
+// versions of Outline express this with an empty workspace permission,
+// older ones with the private flag.
+func (c Collection) private() bool {
+	return c.Private || c.Permission == ""
+}
+
+// collectCollectionAccess exports who can reach a collection, so a
+// collection opened to the whole workspace or the internet by mistake
+// stands out.
+func (e *Exporter) collectCollectionAccess(ch chan<- prometheus.Metric, collection Collection) {
+	permission := collection.Permission
+	if collection.private() {
+		permission = "none"
+	}
+	ch <- prometheus.MustNewConstMetric(e.collectionPrivate, prometheus.GaugeValue,
+		boolValue(collection.private()), collection.ID, collection.Name)
+	ch <- prometheus.MustNewConstMetric(e.collectionPermission, prometheus.GaugeValue,
+		1, collection.ID, collection.Name, permission)
+	ch <- prometheus.MustNewConstMetric(e.collectionSharing, prometheus.GaugeValue,
+		boolValue(collection.Sharing), collection.ID, collection.Name)
+}
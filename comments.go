@@ -0,0 +1,47 @@
+package main
+
+var commentStates = []string{"open", "resolved"}
+
+// commentThreads counts open and resolved comment threads per document.
+// Resolution applies to a whole thread, so replies are not counted. Outline
+// versions that don't expose resolvedAt report every thread as open.
+func commentThreads(comments []Comment) map[string]map[string]int {
+	threads := make(map[string]map[string]int)
+	for _, comment := range comments {
+		if comment.ParentCommentID != "" {
+			continue
+		}
+		state := "open"
+		if !comment.ResolvedAt.IsZero() {
+			state = "resolved"
+		}
+		if threads[comment.DocumentID] == nil {
+			threads[comment.DocumentID] = make(map[string]int)
+		}
+		threads[comment.DocumentID][state]++
+	}
+	return threads
+}
+
+func (s *snapshot) commentIDs() map[string]bool {
+	ids := make(map[string]bool, len(s.comments))
+	for _, comment := range s.comments {
+		ids[comment.ID] = true
+	}
+	return ids
+}
+
+// countNewComments counts the comments and replies that appeared since the
+// previous snapshot, so comment activity can be graphed with rate().
+func (e *Exporter) countNewComments(before, after map[string]bool) {
+	added := 0
+	for id := range after {
+		if !before[id] {
+			added++
+		}
+	}
+	if added > 0 {
+		e.logger.Debug("Comments added since last scrape", "count", added)
+		e.commentsCreated.Add(float64(added))
+	}
+}
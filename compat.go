@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The range of Outline versions the exporter was tested against. Newer
+// versions usually work, but field renames have broken metrics before.
+const (
+	minTestedVersion = "0.72.0"
+	maxTestedVersion = "0.85.99"
+)
+
+type installationInfo struct {
+	Version string `json:"version"`
+}
+
+// preflight asks the server for its version and probes the API endpoints
+// before the exporter starts serving. Problems are logged loudly and exposed
+// as metrics; with COMPATIBILITY_CHECK=strict they abort the startup.
+func (e *Exporter) preflight() error {
+	e.detectCapabilities()
+
+	var problems []string
+	if !e.collectorActive("documents") {
+		problems = append(problems, "documents.list is not available")
+	}
+
+	var response struct {
+		Data installationInfo `json:"data"`
+	}
+	if err := e.fetch(e.ctx, "/api/installation.info", &response, map[string]any{}); err != nil {
+		e.logger.Warn("Could not determine the Outline version", "error", err)
+	} else {
+		e.mu.Lock()
+		e.serverVersion = response.Data.Version
+		e.mu.Unlock()
+		e.logger.Info("Outline server version", "version", response.Data.Version)
+		if !versionTested(response.Data.Version) {
+			problems = append(problems, fmt.Sprintf("Outline %s is outside the tested range %s - %s",
+				response.Data.Version, minTestedVersion, maxTestedVersion))
+		}
+	}
+
+	for _, problem := range problems {
+		e.logger.Warn("Compatibility problem, metrics may be incomplete or wrong", "problem", problem)
+	}
+	if len(problems) > 0 && e.config.CompatibilityCheck == "strict" {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (e *Exporter) collectCompatibility(ch chan<- prometheus.Metric) {
+	e.mu.Lock()
+	version := e.serverVersion
+	e.mu.Unlock()
+	if version == "" {
+		return
+	}
+
+	compatible := 0.0
+	if versionTested(version) {
+		compatible = 1
+	}
+	ch <- prometheus.MustNewConstMetric(e.serverInfo, prometheus.GaugeValue, 1, version)
+	ch <- prometheus.MustNewConstMetric(e.apiCompatible, prometheus.GaugeValue, compatible,
+		version, minTestedVersion, maxTestedVersion)
+}
+
+func versionTested(version string) bool {
+	return compareVersions(version, minTestedVersion) >= 0 && compareVersions(version, maxTestedVersion) <= 0
+}
+
+// compareVersions compares dotted numeric versions such as "0.78.1",
+// ignoring a leading "v" and any pre-release suffix.
+func compareVersions(a, b string) int {
+	partsA, partsB := versionParts(a), versionParts(b)
+	for i := 0; i < max(len(partsA), len(partsB)); i++ {
+		var x, y int
+		if i < len(partsA) {
+			x = partsA[i]
+		}
+		if i < len(partsB) {
+			y = partsB[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(version string) []int {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	version, _, _ = strings.Cut(version, "-")
+	var parts []int
+	for _, part := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
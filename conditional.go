@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// responseCache keeps the decoded response of every request Outline
+// answered with an ETag or Last-Modified header, so the request can be made
+// conditional the next time and a 304 reuses the value instead of
+// downloading and parsing it again. Responses not requested again within
+// CONDITIONAL_REQUESTS_MAX_AGE, e.g. pages past the end of a shrunk list or
+// of an API key no longer used, are dropped so the cache doesn't grow
+// without bound.
+type responseCache struct {
+	maxAge time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cachedResponse
+	sweptAt time.Time
+}
+
+type cachedResponse struct {
+	etag         string
+	lastModified string
+	// value points to a copy of the decoded target.
+	value  reflect.Value
+	usedAt time.Time
+}
+
+// newResponseCache returns nil unless CONDITIONAL_REQUESTS is enabled.
+func newResponseCache(config Config) *responseCache {
+	if !config.ConditionalRequests {
+		return nil
+	}
+	return &responseCache{maxAge: config.ConditionalRequestsAge, entries: make(map[string]cachedResponse), sweptAt: time.Now()}
+}
+
+// prepare adds the conditional headers for a request made before.
+func (c *responseCache) prepare(req *http.Request, cacheKey string) {
+	c.mu.Lock()
+	entry, ok := c.entries[cacheKey]
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	if entry.etag != "" {
+		req.Header.Set("If-None-Match", entry.etag)
+	}
+	if entry.lastModified != "" {
+		req.Header.Set("If-Modified-Since", entry.lastModified)
+	}
+}
+
+// restore copies the cached value of a request answered with 304 into
+// target. It reports false when there is nothing to restore.
+func (c *responseCache) restore(cacheKey string, target any) bool {
+	c.mu.Lock()
+	entry, ok := c.entries[cacheKey]
+	if ok {
+		entry.usedAt = time.Now()
+		c.entries[cacheKey] = entry
+	}
+	c.mu.Unlock()
+	destination := reflect.ValueOf(target)
+	if !ok || destination.Kind() != reflect.Pointer || destination.Type() != entry.value.Type() {
+		return false
+	}
+	destination.Elem().Set(entry.value.Elem())
+	return true
+}
+
+// store remembers the decoded response when it can be validated later.
+func (c *responseCache) store(cacheKey string, resp *http.Response, target any) {
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	source := reflect.ValueOf(target)
+	if (etag == "" && lastModified == "") || source.Kind() != reflect.Pointer {
+		return
+	}
+	value := reflect.New(source.Type().Elem())
+	value.Elem().Set(source.Elem())
+
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[cacheKey] = cachedResponse{etag: etag, lastModified: lastModified, value: value, usedAt: now}
+	if c.maxAge > 0 && now.Sub(c.sweptAt) >= c.maxAge {
+		for key, entry := range c.entries {
+			if now.Sub(entry.usedAt) >= c.maxAge {
+				delete(c.entries, key)
+			}
+		}
+		c.sweptAt = now
+	}
+}
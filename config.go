@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileValues holds the settings of the --config.file YAML file, keyed by the
+// environment variable they stand for. Environment variables override them.
+var fileValues map[string]string
+
+// loadConfigFile reads a YAML configuration file. Top-level keys are the
+// environment variable names in lower case (outline_api_url, page_limit,
+// ...), lists become comma separated values. A few options have a structured
+// form that is easier to write in YAML than in a single variable:
+//
+//	collectors:
+//	  comments: false
+//	saved_searches:
+//	  sunset: LegacyProduct
+//	probe_targets:
+//	  https://staging.example.com: [ol_api_xxx]
+//	instances:
+//	  - name: prod
+//	    url: https://wiki.example.com
+//	    api_key: ol_api_yyy
+func loadConfigFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var file map[string]any
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	values, err := flattenConfig(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	fileValues = values
+	return nil
+}
+
+func flattenConfig(file map[string]any) (map[string]string, error) {
+	values := make(map[string]string)
+	for key, value := range file {
+		name := strings.ToUpper(key)
+		var err error
+		switch name {
+		case "COLLECTORS":
+			err = eachEntry(value, func(collector string, enabled any) error {
+				flat, err := scalar(enabled)
+				values["COLLECTOR_"+strings.ToUpper(collector)] = flat
+				return err
+			})
+		case "SAVED_SEARCHES", "PROBE_TARGETS", "BASIC_AUTH_USERS":
+			var pairs []string
+			err = eachEntry(value, func(key string, value any) error {
+				flat, err := scalar(value)
+				pairs = append(pairs, key+"="+flat)
+				return err
+			})
+			values[name] = strings.Join(pairs, ";")
+		case "INSTANCES":
+			err = flattenInstances(value, values)
+		default:
+			values[name], err = scalar(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return values, nil
+}
+
+func flattenInstances(value any, values map[string]string) error {
+	list, ok := value.([]any)
+	if !ok {
+		return fmt.Errorf("expected a list of instances")
+	}
+	var names []string
+	for _, item := range list {
+		instance, ok := item.(map[string]any)
+		if !ok {
+			return fmt.Errorf("expected name, url and api_key for every instance")
+		}
+		name, _ := instance["name"].(string)
+		if name == "" {
+			return fmt.Errorf("instance without a name")
+		}
+		names = append(names, name)
+		suffix := instanceSuffix(name)
+		var err error
+		if values["OUTLINE_API_URL_"+suffix], err = scalar(instance["url"]); err != nil {
+			return err
+		}
+		if values["OUTLINE_API_KEY_"+suffix], err = scalar(instance["api_key"]); err != nil {
+			return err
+		}
+	}
+	values["OUTLINE_INSTANCES"] = strings.Join(names, ",")
+	return nil
+}
+
+// eachEntry calls fn for every entry of a YAML mapping in key order.
+func eachEntry(value any, fn func(key string, value any) error) error {
+	entries, ok := value.(map[string]any)
+	if !ok {
+		return fmt.Errorf("expected a mapping")
+	}
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := fn(key, entries[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scalar renders a YAML value the way it would be written in an environment
+// variable.
+func scalar(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			flat, err := scalar(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, flat)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]any:
+		return "", fmt.Errorf("unexpected mapping")
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
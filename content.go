@@ -0,0 +1,150 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var (
+	markdownLink  = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	markdownImage = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	bareURL       = regexp.MustCompile(`\b(?:https?|ftp|mailto|data):[^\s)]+`)
+	taskItem      = regexp.MustCompile(`(?m)^[ \t]*[-*+][ \t]+\[([ xX])\]`)
+)
+
+type contentAnalyzer struct {
+	ownerField string
+	ownerLine  *regexp.Regexp
+	tagPattern *regexp.Regexp
+}
+
+func newContentAnalyzer(config Config) *contentAnalyzer {
+	analyzer := &contentAnalyzer{ownerField: config.OwnerField}
+	if config.OwnerField != "" {
+		analyzer.ownerLine = regexp.MustCompile(`(?im)^[\s>*_-]*` + regexp.QuoteMeta(config.OwnerField) + `[*_]*\s*:[*_]*[ \t]*(.+?)[ \t]*$`)
+	}
+	if config.TagPattern != "" {
+		analyzer.tagPattern = regexp.MustCompile(config.TagPattern)
+	}
+	return analyzer
+}
+
+// owner returns the document owner declared either in a YAML frontmatter
+// block or in an "Owner: name" line of the body. Frontmatter wins when both
+// are present.
+func (a *contentAnalyzer) owner(text string) string {
+	if a.ownerField == "" {
+		return ""
+	}
+	if owner := a.frontmatterOwner(text); owner != "" {
+		return owner
+	}
+	if match := a.ownerLine.FindStringSubmatch(text); match != nil {
+		return cleanOwner(match[1])
+	}
+	return ""
+}
+
+func (a *contentAnalyzer) frontmatterOwner(text string) string {
+	lines := strings.Split(text, "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return ""
+	}
+
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line == "---" || line == "..." {
+			break
+		}
+		key, value, found := strings.Cut(line, ":")
+		if found && strings.EqualFold(strings.TrimSpace(key), a.ownerField) {
+			return cleanOwner(value)
+		}
+	}
+	return ""
+}
+
+func cleanOwner(value string) string {
+	if match := markdownLink.FindStringSubmatch(value); match != nil {
+		value = match[1]
+	}
+	return strings.Trim(value, " \t*_\"'@`")
+}
+
+// tags returns the sorted, de-duplicated tags found in the text. When the
+// pattern has a capture group the first group is used as the tag name,
+// otherwise the whole match is.
+func (a *contentAnalyzer) tags(text string) []string {
+	if a.tagPattern == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var tags []string
+	for _, match := range a.tagPattern.FindAllStringSubmatch(text, -1) {
+		tag := match[0]
+		if len(match) > 1 && match[1] != "" {
+			tag = match[1]
+		}
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// wordCount counts the words of the rendered text: images, link targets,
+// URLs and embedded data are dropped and markdown syntax isn't counted, so
+// the count isn't skewed the way the byte size is.
+func wordCount(text string) int {
+	text = markdownImage.ReplaceAllString(text, " ")
+	text = markdownLink.ReplaceAllString(text, "$1")
+	text = bareURL.ReplaceAllString(text, " ")
+
+	words := 0
+	for _, field := range strings.Fields(text) {
+		if strings.IndexFunc(field, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0 {
+			words++
+		}
+	}
+	return words
+}
+
+// tasks returns the number of checklist items of a document and how many of
+// them are checked. Outline reports the counts itself; older versions don't,
+// and the items are counted in the markdown instead.
+func tasks(document Document) (total, completed int) {
+	if document.Tasks != nil {
+		return document.Tasks.Total, document.Tasks.Completed
+	}
+	for _, match := range taskItem.FindAllStringSubmatch(document.Text, -1) {
+		total++
+		if match[1] != " " {
+			completed++
+		}
+	}
+	return total, completed
+}
+
+// dropText clears the text of the documents, keeping its size.
+func dropText(documents []Document) {
+	for i := range documents {
+		documents[i].TextSize = len(documents[i].Text)
+		documents[i].Text = ""
+	}
+}
+
+// size returns the length of the document text in bytes, also once the
+// text was dropped.
+func (d Document) size() int {
+	if d.Text == "" {
+		return d.TextSize
+	}
+	return len(d.Text)
+}
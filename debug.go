@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"regexp"
+)
+
+// sensitiveHeaders are masked in debug dumps unless DEBUG_UNSAFE is set, so
+// logs shipped to a central system don't leak the API key.
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// documentText matches the text field of documents in API responses.
+var documentText = regexp.MustCompile(`"text":"(?:[^"\\]|\\.)*"`)
+
+func redactHeaders(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, name := range sensitiveHeaders {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, "[REDACTED]")
+		}
+	}
+	return redacted
+}
+
+// redactText replaces document text in a response body with its length.
+func redactText(body []byte) []byte {
+	return documentText.ReplaceAllFunc(body, func(match []byte) []byte {
+		return []byte(fmt.Sprintf(`"text":"[REDACTED %d bytes]"`, len(match)-len(`"text":""`)))
+	})
+}
+
+func (e *Exporter) dumpRequest(req *http.Request) {
+	if !e.config.Debug {
+		return
+	}
+	dumped := req.Clone(req.Context())
+	if !e.config.DebugUnsafe {
+		dumped.Header = redactHeaders(req.Header)
+	}
+	if req.GetBody != nil {
+		// Dump a copy of the body, the original is still to be sent.
+		dumped.Body, _ = req.GetBody()
+	}
+	if dump, err := httputil.DumpRequestOut(dumped, dumped.Body != nil); err == nil {
+		e.logger.Debug("Outline API request dump", "dump", string(dump))
+	}
+}
+
+func (e *Exporter) dumpResponse(resp *http.Response, body []byte) {
+	if !e.config.Debug {
+		return
+	}
+	if !e.config.DebugUnsafe {
+		redacted := *resp
+		redacted.Header = redactHeaders(resp.Header)
+		resp = &redacted
+		body = redactText(body)
+	}
+	if dump, err := httputil.DumpResponse(resp, false); err == nil {
+		e.logger.Debug("Outline API response dump", "dump", string(dump), "body", string(body))
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxErrorBodyLength bounds the response body kept per error, Outline error
+// pages can be large HTML documents.
+const maxErrorBodyLength = 512
+
+type apiErrorEntry struct {
+	Time     time.Time `json:"time"`
+	Instance string    `json:"instance,omitempty"`
+	Endpoint string    `json:"endpoint"`
+	Status   int       `json:"status,omitempty"`
+	Body     string    `json:"body,omitempty"`
+	Error    string    `json:"error"`
+}
+
+// errorLog is a fixed-size ring buffer of the most recent API errors, so
+// intermittent failures can be diagnosed without trawling the logs.
+type errorLog struct {
+	instance string
+
+	mu      sync.Mutex
+	entries []apiErrorEntry
+	next    int
+	full    bool
+}
+
+func newErrorLog(size int, instance string) *errorLog {
+	return &errorLog{instance: instance, entries: make([]apiErrorEntry, max(size, 1))}
+}
+
+func (l *errorLog) record(path string, err error) {
+	entry := apiErrorEntry{Time: time.Now(), Instance: l.instance, Endpoint: path, Error: err.Error()}
+	if u, parseErr := url.Parse(path); parseErr == nil {
+		entry.Endpoint = u.Path
+	}
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		entry.Status = apiErr.StatusCode
+		entry.Body = truncate(apiErr.Body, maxErrorBodyLength)
+		entry.Error = http.StatusText(apiErr.StatusCode)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// list returns the recorded errors, most recent first.
+func (l *errorLog) list() []apiErrorEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	count := l.next
+	if l.full {
+		count = len(l.entries)
+	}
+	list := make([]apiErrorEntry, 0, count)
+	for i := 1; i <= count; i++ {
+		list = append(list, l.entries[(l.next-i+len(l.entries))%len(l.entries)])
+	}
+	return list
+}
+
+func (l *errorLog) last() time.Time {
+	if list := l.list(); len(list) > 0 {
+		return list[0].Time
+	}
+	return time.Time{}
+}
+
+// handleErrors serves the recent errors of every instance, newest first.
+func handleErrors(exporters func() []*Exporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list := []apiErrorEntry{}
+		for _, exporter := range exporters() {
+			list = append(list, exporter.errors.list()...)
+		}
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Time.After(list[j].Time) })
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+// errorClass groups a failed request by cause, so an expired API key can be
+// told apart from a transient network error.
+func errorClass(err error) string {
+	var apiErr *apiError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &apiErr):
+		switch {
+		case apiErr.StatusCode == http.StatusUnauthorized, apiErr.StatusCode == http.StatusForbidden:
+			return "auth"
+		case apiErr.StatusCode == http.StatusTooManyRequests:
+			return "rate_limit"
+		case apiErr.StatusCode >= http.StatusInternalServerError:
+			return "http_5xx"
+		default:
+			return "http_4xx"
+		}
+	case strings.Contains(err.Error(), "decode body"):
+		return "decode"
+	case strings.Contains(err.Error(), "timeout"):
+		return "timeout"
+	}
+	return "network"
+}
+
+func truncate(value string, length int) string {
+	if len(value) <= length {
+		return value
+	}
+	return value[:length] + "..."
+}
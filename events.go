@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// maxEventPages bounds how far back a single scrape walks the event log,
+// e.g. after the exporter was unable to reach Outline for a long time.
+const maxEventPages = 50
+
+type Event struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// eventCursor remembers the newest event counted so far. Its mutex is held
+// for a whole walk of the log so concurrent scrapes don't count the same
+// events twice.
+type eventCursor struct {
+	mu      sync.Mutex
+	started bool
+	last    Event
+}
+
+// fetchEvents counts the events created since the previous call into
+// outline_events_total. The event log is read newest first until the last
+// counted event is reached, so no event is counted twice. The first call
+// only records where the log currently ends: history before the exporter
+// started is not counted.
+func (e *Exporter) fetchEvents(ctx context.Context) error {
+	cursor := &e.events
+	cursor.mu.Lock()
+	defer cursor.mu.Unlock()
+
+	limit := e.config.PageLimit
+	if !cursor.started {
+		limit = 1
+	}
+
+	counts := make(map[string]int)
+	var newest Event
+	offset := 0
+pages:
+	for page := 0; page < maxEventPages; page++ {
+		var response apiResp[Event]
+		body := map[string]any{"limit": limit, "offset": offset, "sort": "createdAt", "direction": "DESC"}
+		if err := fetchPage(ctx, e, "/api/events.list", &response, body); err != nil {
+			return err
+		}
+		for _, event := range response.Data {
+			if cursor.started && (event.ID == cursor.last.ID || event.CreatedAt.Before(cursor.last.CreatedAt)) {
+				break pages
+			}
+			if newest.ID == "" {
+				newest = event
+			}
+			counts[event.Name]++
+		}
+		if !cursor.started || len(response.Data) < limit {
+			break
+		}
+		offset += limit
+	}
+
+	if cursor.started {
+		for name, count := range counts {
+			e.eventsTotal.WithLabelValues(name).Add(float64(count))
+		}
+	}
+	cursor.started = true
+	if newest.ID != "" {
+		cursor.last = newest
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	fileOperationTypes  = []string{"export", "import"}
+	fileOperationStates = []string{"creating", "uploading", "complete", "error", "expired"}
+)
+
+// fetchFileOperations lists the export and import jobs. fileOperations.list
+// requires a type, so each one is listed separately.
+func (e *Exporter) fetchFileOperations(ctx context.Context) ([]FileOperation, error) {
+	var operations []FileOperation
+	for _, operationType := range fileOperationTypes {
+		page, err := fetchAll[FileOperation](ctx, e, "/api/fileOperations.list", map[string]any{"type": operationType})
+		if err != nil {
+			return nil, fmt.Errorf("list %s operations: %w", operationType, err)
+		}
+		operations = append(operations, page...)
+	}
+	return operations, nil
+}
+
+// incomplete reports whether the job is still running, or stuck.
+func (o FileOperation) incomplete() bool {
+	return o.State == "creating" || o.State == "uploading"
+}
+
+// collectFileOperations exports the export and import jobs by state. Every
+// known state is exported, with zero when no job is in it, so alerts on the
+// oldest incomplete job don't go absent when the queue is empty.
+func (e *Exporter) collectFileOperations(ch chan<- prometheus.Metric, operations []FileOperation) {
+	counts := make(map[[2]string]int)
+	oldest := make(map[string]float64)
+	for _, operationType := range fileOperationTypes {
+		for _, state := range fileOperationStates {
+			counts[[2]string{operationType, state}] = 0
+		}
+		oldest[operationType] = 0
+	}
+
+	for _, operation := range operations {
+		counts[[2]string{operation.Type, operation.State}]++
+		if operation.incomplete() {
+			oldest[operation.Type] = max(oldest[operation.Type], e.age(operation.CreatedAt))
+		}
+	}
+
+	for key, count := range counts {
+		ch <- prometheus.MustNewConstMetric(e.fileOperations, prometheus.GaugeValue, float64(count), key[0], key[1])
+	}
+	for operationType, age := range oldest {
+		ch <- prometheus.MustNewConstMetric(e.fileOperationOldestIncomplete, prometheus.GaugeValue, age, operationType)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// collectionFilter selects the monitored collections from
+// COLLECTIONS_INCLUDE and COLLECTIONS_EXCLUDE. Entries match a collection
+// by ID or name; entries wrapped in slashes are regular expressions matched
+// against both.
+type collectionFilter struct {
+	include []collectionMatcher
+	exclude []collectionMatcher
+}
+
+type collectionMatcher struct {
+	value   string
+	pattern *regexp.Regexp
+}
+
+func newCollectionFilter(include, exclude []string) (*collectionFilter, error) {
+	filter := &collectionFilter{}
+	for _, list := range []struct {
+		entries  []string
+		matchers *[]collectionMatcher
+	}{{include, &filter.include}, {exclude, &filter.exclude}} {
+		for _, entry := range list.entries {
+			matcher := collectionMatcher{value: entry}
+			if len(entry) > 2 && strings.HasPrefix(entry, "/") && strings.HasSuffix(entry, "/") {
+				pattern, err := regexp.Compile(entry[1 : len(entry)-1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid collection pattern %s: %w", entry, err)
+				}
+				matcher.pattern = pattern
+			}
+			*list.matchers = append(*list.matchers, matcher)
+		}
+	}
+	return filter, nil
+}
+
+func (m collectionMatcher) matches(collection Collection) bool {
+	if m.pattern != nil {
+		return m.pattern.MatchString(collection.ID) || (collection.Name != "" && m.pattern.MatchString(collection.Name))
+	}
+	return m.value == collection.ID || m.value == collection.Name
+}
+
+func (f *collectionFilter) active() bool {
+	return f != nil && (len(f.include) > 0 || len(f.exclude) > 0)
+}
+
+// allows reports whether the collection is monitored: it must match an
+// include entry, if there are any, and no exclude entry.
+func (f *collectionFilter) allows(collection Collection) bool {
+	if !f.active() {
+		return true
+	}
+	included := len(f.include) == 0
+	for _, matcher := range f.include {
+		if matcher.matches(collection) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, matcher := range f.exclude {
+		if matcher.matches(collection) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveCollections lists the collections to find which ones the filter
+// allows, so documents, which only carry a collection ID, can be filtered by
+// collection name. When the list fails the previous result is kept.
+func (e *Exporter) resolveCollections(ctx context.Context) {
+	collections, err := fetchAll[Collection](ctx, e, "/api/collections.list", nil)
+	if err != nil {
+		e.logger.Warn("Error resolving the collection filter", "error", err)
+		return
+	}
+
+	allowed := make(map[string]bool)
+	for _, collection := range collections {
+		if e.config.CollectionFilter.allows(collection) {
+			allowed[collection.ID] = true
+		}
+	}
+	e.mu.Lock()
+	e.allowedCollections = allowed
+	e.mu.Unlock()
+}
+
+// collectionAllowed reports whether documents of the collection are
+// monitored. Until the collections could be resolved, only the entries
+// matching IDs apply.
+func (e *Exporter) collectionAllowed(collectionID string) bool {
+	if !e.config.CollectionFilter.active() {
+		return true
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.allowedCollections != nil {
+		return e.allowedCollections[collectionID]
+	}
+	return e.config.CollectionFilter.allows(Collection{ID: collectionID})
+}
+
+// listDocuments lists documents. When collections are included explicitly,
+// each allowed collection is listed on its own with a collectionId parameter
+// instead of crawling the whole workspace and discarding most of it.
+func (e *Exporter) listDocuments(ctx context.Context) ([]Document, error) {
+	collectionIDs, perCollection := e.includedCollections()
+	if !perCollection {
+		documents, err := fetchAll[Document](ctx, e, "/api/documents.list", nil)
+		return e.filterDocuments(documents), err
+	}
+
+	var documents []Document
+	for _, collectionID := range collectionIDs {
+		page, err := fetchAll[Document](ctx, e, "/api/documents.list", map[string]any{"collectionId": collectionID})
+		if err != nil {
+			return documents, fmt.Errorf("collection %s: %w", collectionID, err)
+		}
+		documents = append(documents, page...)
+	}
+	return documents, nil
+}
+
+// includedCollections returns the collections to list documents from one by
+// one, which only applies once the included collections were resolved.
+func (e *Exporter) includedCollections() ([]string, bool) {
+	filter := e.config.CollectionFilter
+	e.mu.Lock()
+	var collectionIDs []string
+	for collectionID := range e.allowedCollections {
+		collectionIDs = append(collectionIDs, collectionID)
+	}
+	resolved := e.allowedCollections != nil
+	e.mu.Unlock()
+
+	if !filter.active() || len(filter.include) == 0 || !resolved {
+		return nil, false
+	}
+	sort.Strings(collectionIDs)
+	return collectionIDs, true
+}
+
+// filterCollections keeps only the monitored collections.
+func (e *Exporter) filterCollections(collections []Collection) []Collection {
+	if !e.config.CollectionFilter.active() {
+		return collections
+	}
+
+	var filtered []Collection
+	for _, collection := range collections {
+		if e.config.CollectionFilter.allows(collection) {
+			filtered = append(filtered, collection)
+		}
+	}
+	return filtered
+}
+
+// filterDocuments keeps only the documents of monitored collections, for
+// endpoints that can't be asked for a single collection.
+func (e *Exporter) filterDocuments(documents []Document) []Document {
+	if !e.config.CollectionFilter.active() {
+		return documents
+	}
+
+	var filtered []Document
+	for _, document := range documents {
+		if e.collectionAllowed(document.CollectionId) {
+			filtered = append(filtered, document)
+		}
+	}
+	return filtered
+}
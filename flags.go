@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"strconv"
+	"strings"
+)
+
+// collectorFlags holds the collectors enabled or disabled on the command
+// line. They override COLLECTOR_<NAME> and the configuration file, and keep
+// applying when the configuration is reloaded.
+var collectorFlags = make(map[string]bool)
+
+// registerCollectorFlags adds node_exporter style --collector.<name> and
+// --no-collector.<name> flags for every collector.
+func registerCollectorFlags(flags *flag.FlagSet) {
+	for _, collector := range collectorEndpoints {
+		name := strings.ReplaceAll(collector.name, "_", "-")
+		flags.BoolFunc("collector."+name, "enable the "+collector.name+" collector", func(value string) error {
+			enabled, err := strconv.ParseBool(value)
+			collectorFlags[collector.name] = enabled
+			return err
+		})
+		flags.BoolFunc("no-collector."+name, "disable the "+collector.name+" collector", func(string) error {
+			collectorFlags[collector.name] = false
+			return nil
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// listKey implements listKeyed: recent Outline versions return the groups
+// of groups.list together with their memberships in an object.
+func (Group) listKey() string {
+	return "groups"
+}
+
+func (e *Exporter) collectGroups(ch chan<- prometheus.Metric, groups []Group) {
+	ch <- prometheus.MustNewConstMetric(e.groupsTotal, prometheus.GaugeValue, float64(len(groups)))
+	for _, group := range groups {
+		ch <- prometheus.MustNewConstMetric(e.groupMembers, prometheus.GaugeValue,
+			float64(group.MemberCount), group.ID, group.Name)
+		e.collectAge(ch, e.groupAge, e.groupCreatedTimestamp,
+			group.CreatedAt, group.ID, group.Name)
+	}
+}
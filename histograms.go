@@ -0,0 +1,68 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// histogram accumulates observations into the cumulative buckets of a
+// constant histogram metric.
+type histogram struct {
+	upperBounds []float64
+	count       uint64
+	sum         float64
+	buckets     map[float64]uint64
+}
+
+func newHistogram(upperBounds []float64) *histogram {
+	h := &histogram{upperBounds: upperBounds, buckets: make(map[float64]uint64, len(upperBounds))}
+	for _, bound := range upperBounds {
+		h.buckets[bound] = 0
+	}
+	return h
+}
+
+// secondsBuckets converts durations to upper bounds in seconds.
+func secondsBuckets(durations []time.Duration) []float64 {
+	bounds := make([]float64, len(durations))
+	for i, duration := range durations {
+		bounds[i] = duration.Seconds()
+	}
+	return bounds
+}
+
+func (h *histogram) observe(value float64) {
+	h.count++
+	h.sum += value
+	for _, bound := range h.upperBounds {
+		if value <= bound {
+			h.buckets[bound]++
+		}
+	}
+}
+
+// collectionHistograms holds one histogram per collection.
+type collectionHistograms struct {
+	upperBounds []float64
+	byID        map[string]*histogram
+}
+
+func newCollectionHistograms(upperBounds []float64) *collectionHistograms {
+	return &collectionHistograms{upperBounds: upperBounds, byID: make(map[string]*histogram)}
+}
+
+func (c *collectionHistograms) observe(collectionID string, value float64) {
+	h := c.byID[collectionID]
+	if h == nil {
+		h = newHistogram(c.upperBounds)
+		c.byID[collectionID] = h
+	}
+	h.observe(value)
+}
+
+func (c *collectionHistograms) collect(ch chan<- prometheus.Metric, desc *prometheus.Desc, collectionNames map[string]string) {
+	for collectionID, h := range c.byID {
+		ch <- prometheus.MustNewConstHistogram(desc, h.count, h.sum, h.buckets, collectionID, collectionNames[collectionID])
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// documentInventory is the document list kept between scrapes with
+// INCREMENTAL_SCRAPE. Its mutex is held for a whole refresh so concurrent
+// scrapes don't merge the same changes twice.
+type documentInventory struct {
+	mu        sync.Mutex
+	documents map[string]Document
+	// since is the newest updatedAt merged so far, as reported by Outline so
+	// clock skew between the hosts doesn't matter.
+	since  time.Time
+	fullAt time.Time
+}
+
+// fetchDocuments returns the documents, either from a full walk of
+// documents.list or, with INCREMENTAL_SCRAPE, from the inventory updated
+// with the documents changed since the previous scrape.
+func (e *Exporter) fetchDocuments(ctx context.Context) ([]Document, error) {
+	if !e.config.IncrementalScrape {
+		return e.listDocuments(ctx)
+	}
+
+	inventory := &e.inventory
+	inventory.mu.Lock()
+	defer inventory.mu.Unlock()
+
+	if inventory.documents == nil || time.Since(inventory.fullAt) >= e.config.FullScrapeInterval {
+		documents, err := e.listDocuments(ctx)
+		if err != nil {
+			return documents, err
+		}
+		inventory.documents = make(map[string]Document, len(documents))
+		inventory.since = time.Time{}
+		inventory.merge(documents)
+		inventory.fullAt = time.Now()
+		e.logger.Debug("Document inventory rebuilt", "documents", len(documents))
+		return inventory.list(), nil
+	}
+
+	changed, err := e.fetchChangedDocuments(ctx, inventory.since)
+	if err != nil {
+		return nil, err
+	}
+	inventory.merge(e.filterDocuments(changed))
+	e.logger.Debug("Document inventory updated", "changed", len(changed), "documents", len(inventory.documents))
+	return inventory.list(), nil
+}
+
+// fetchChangedDocuments reads documents.list most recently updated first
+// until it reaches documents last updated before since, collection by
+// collection when only some collections are included. Documents updated
+// exactly at since are fetched again, which merging makes harmless.
+func (e *Exporter) fetchChangedDocuments(ctx context.Context, since time.Time) ([]Document, error) {
+	collectionIDs, perCollection := e.includedCollections()
+	if !perCollection {
+		return e.fetchChangedIn(ctx, since, nil)
+	}
+
+	var changed []Document
+	for _, collectionID := range collectionIDs {
+		documents, err := e.fetchChangedIn(ctx, since, map[string]any{"collectionId": collectionID})
+		if err != nil {
+			return nil, fmt.Errorf("collection %s: %w", collectionID, err)
+		}
+		changed = append(changed, documents...)
+	}
+	return changed, nil
+}
+
+func (e *Exporter) fetchChangedIn(ctx context.Context, since time.Time, params map[string]any) ([]Document, error) {
+	limit := e.config.PageLimit
+	var changed []Document
+	for offset := 0; ; offset += limit {
+		var response apiResp[Document]
+		body := map[string]any{"limit": limit, "offset": offset, "sort": "updatedAt", "direction": "DESC"}
+		for key, value := range params {
+			body[key] = value
+		}
+		if err := fetchPage(ctx, e, "/api/documents.list", &response, body); err != nil {
+			return nil, err
+		}
+		for _, document := range response.Data {
+			if document.UpdatedAt.Before(since) {
+				return changed, nil
+			}
+			changed = append(changed, document)
+		}
+		if len(response.Data) < limit {
+			return changed, nil
+		}
+	}
+}
+
+// reconcileInventory drops the documents archived, deleted or unpublished
+// since they were merged into the inventory, as listed by the same scrape.
+// Those changes don't always move a document up documents.list sorted by
+// updatedAt, so the documents would otherwise stay exported until the next
+// full listing. Documents updated again since are kept.
+func (e *Exporter) reconcileInventory(snap *snapshot) {
+	if !e.config.IncrementalScrape || !snap.fetched("documents") {
+		return
+	}
+	var gone []Document
+	for resource, documents := range map[string][]Document{
+		"archived": snap.archived,
+		"deleted":  snap.deleted,
+		"drafts":   snap.drafts,
+	} {
+		if snap.fetched(resource) {
+			gone = append(gone, documents...)
+		}
+	}
+
+	inventory := &e.inventory
+	inventory.mu.Lock()
+	defer inventory.mu.Unlock()
+	removed := 0
+	for _, document := range gone {
+		if kept, ok := inventory.documents[document.ID]; ok && !kept.UpdatedAt.After(document.UpdatedAt) {
+			delete(inventory.documents, document.ID)
+			removed++
+		}
+	}
+	if removed > 0 {
+		e.logger.Debug("Documents removed from the inventory", "documents", removed)
+		snap.documents = inventory.list()
+	}
+}
+
+func (i *documentInventory) merge(documents []Document) {
+	for _, document := range documents {
+		i.documents[document.ID] = document
+		if document.UpdatedAt.After(i.since) {
+			i.since = document.UpdatedAt
+		}
+	}
+}
+
+func (i *documentInventory) list() []Document {
+	documents := make([]Document, 0, len(i.documents))
+	for _, document := range i.documents {
+		documents = append(documents, document)
+	}
+	return documents
+}
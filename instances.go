@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Instance is one Outline wiki monitored by this exporter.
+type Instance struct {
+	Name string
+	URL  string
+	Keys []string
+}
+
+// getInstances reads the instance names listed in key and the URL and API
+// keys of each one from OUTLINE_API_URL_<NAME> and OUTLINE_API_KEY_<NAME>.
+func getInstances(key string) []Instance {
+	var instances []Instance
+	for _, name := range getList(key) {
+		suffix := instanceSuffix(name)
+		instance := Instance{
+			Name: name,
+			URL:  getEnv("OUTLINE_API_URL_"+suffix, ""),
+			Keys: getList("OUTLINE_API_KEY_" + suffix),
+		}
+		if instance.URL == "" || len(instance.Keys) == 0 {
+			configError("instance %s needs OUTLINE_API_URL_%s and OUTLINE_API_KEY_%s", name, suffix, suffix)
+			continue
+		}
+		instances = append(instances, instance)
+	}
+	return instances
+}
+
+// instanceSuffix turns an instance name into the suffix of its variables.
+func instanceSuffix(name string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+// newExporters creates one exporter per configured instance, checking it
+// first with COMPATIBILITY_CHECK=strict.
+// With several instances every metric carries an instance_name label; a
+// single instance configured through OUTLINE_API_URL keeps the unlabelled
+// metrics.
+func newExporters(ctx context.Context, config Config) ([]*Exporter, error) {
+	configs := []Config{config}
+	if len(config.Instances) > 0 {
+		configs = nil
+		for _, instance := range config.Instances {
+			instanceConfig := config
+			instanceConfig.InstanceName = instance.Name
+			instanceConfig.OutlineAPIURL = instance.URL
+			instanceConfig.OutlineAPIKeys = instance.Keys
+			configs = append(configs, instanceConfig)
+		}
+	}
+
+	var exporters []*Exporter
+	for _, config := range configs {
+		exporter := newExporter(config)
+		exporter.ctx = ctx
+		if config.InstanceName != "" {
+			slog.Info("Monitoring instance", "instance", config.InstanceName, "url", config.OutlineAPIURL)
+		}
+		// Only a strict check holds up the startup, the warning one runs in
+		// the background once the exporter is started.
+		if config.CompatibilityCheck == "strict" {
+			if err := exporter.preflight(); err != nil {
+				return nil, fmt.Errorf("compatibility check failed: %w (set COMPATIBILITY_CHECK=warn to start anyway)", err)
+			}
+		}
+		exporters = append(exporters, exporter)
+	}
+	return exporters, nil
+}
+
+// registerer wraps registerer to add the instance_name label of the
+// exporter, if any.
+func (e *Exporter) registerer(registerer prometheus.Registerer) prometheus.Registerer {
+	if e.config.InstanceName == "" {
+		return registerer
+	}
+	return prometheus.WrapRegistererWith(prometheus.Labels{"instance_name": e.config.InstanceName}, registerer)
+}
+
+// boundExporter collects an exporter with the context of a scrape request,
+// so fetching the data on demand stops as soon as Prometheus gives up and
+// closes the connection.
+type boundExporter struct {
+	*Exporter
+	ctx context.Context
+}
+
+func (b boundExporter) Collect(ch chan<- prometheus.Metric) {
+	b.collect(b.ctx, ch)
+}
+
+// start launches the background work of the exporter, which runs until its
+// context is cancelled.
+func (e *Exporter) start() {
+	go func() {
+		if e.config.CompatibilityCheck == "warn" {
+			e.preflight()
+		}
+		e.watchCapabilities(e.config.CompatibilityCheck == "off")
+	}()
+	if e.config.ScrapeInterval > 0 {
+		go e.refreshLoop()
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"sync/atomic"
+)
+
+// keyPool hands out the configured API keys in turn so the request load is
+// spread over all of them, keeping each one under Outline's per-key rate
+// limits.
+type keyPool struct {
+	keys []string
+	next atomic.Uint64
+}
+
+func newKeyPool(keys []string) *keyPool {
+	return &keyPool{keys: keys}
+}
+
+// pick returns the next key together with the label identifying it in the
+// per-key metrics. Keys are labelled by their position in OUTLINE_API_KEY so
+// the secrets themselves never end up in Prometheus.
+func (p *keyPool) pick() (key, label string) {
+	if len(p.keys) == 0 {
+		return "", "0"
+	}
+	i := int((p.next.Add(1) - 1) % uint64(len(p.keys)))
+	return p.keys[i], strconv.Itoa(i)
+}
+
+func (e *Exporter) recordKeyUsage(label string, err error) {
+	e.apiKeyRequests.WithLabelValues(label).Inc()
+	if err == nil {
+		return
+	}
+	status := "error"
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		status = strconv.Itoa(apiErr.StatusCode)
+	}
+	e.apiKeyErrors.WithLabelValues(label, status).Inc()
+}
+
+// throttled reports whether another key may succeed where this request was
+// rate limited, without waiting.
+func (e *Exporter) throttled(err error) bool {
+	var apiErr *apiError
+	return len(e.keys.keys) > 1 && errors.As(err, &apiErr) && apiErr.StatusCode == 429
+}
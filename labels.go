@@ -0,0 +1,31 @@
+package main
+
+import "strings"
+
+// documentLabelNames returns the labels identifying a document on
+// per-document metrics. The collection name is resolved from the collection
+// list so dashboards can group by it. The title and slug are opt-in: they
+// make dashboards readable but change whenever a document is renamed.
+func documentLabelNames(config Config, extra ...string) []string {
+	names := []string{"document_id", "collection_id", "collection_name"}
+	if config.DocumentTitleLabels {
+		names = append(names, "title", "slug")
+	}
+	return append(names, extra...)
+}
+
+// documentLabelValues returns the values of documentLabelNames for a
+// document, followed by the extra values.
+func (e *Exporter) documentLabelValues(document Document, collectionNames map[string]string, extra ...string) []string {
+	values := []string{document.ID, document.CollectionId, collectionNames[document.CollectionId]}
+	if e.config.DocumentTitleLabels {
+		values = append(values, document.Title, document.slug())
+	}
+	return append(values, extra...)
+}
+
+// slug returns the readable part of the document URL, /doc/<slug>-<urlId>.
+func (d Document) slug() string {
+	slug := strings.TrimPrefix(d.URL, "/doc/")
+	return strings.TrimSuffix(strings.TrimSuffix(slug, d.URLID), "-")
+}
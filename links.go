@@ -0,0 +1,33 @@
+package main
+
+import "regexp"
+
+// Outline links documents as /doc/<slug>-<urlId>, relative or prefixed with
+// the wiki's address. The urlId alone identifies the document.
+var internalLink = regexp.MustCompile(`\]\((?:https?://[^/\s)]+)?/doc/(?:[^)\s#?]*-)?([A-Za-z0-9]{10})(?:[#?][^)\s]*)?\)`)
+
+// documentURLIDs returns the urlIds of every fetched document, including
+// drafts, templates and archived ones, which links can still point to.
+func (s *snapshot) documentURLIDs() map[string]bool {
+	ids := make(map[string]bool)
+	for _, documents := range [][]Document{s.documents, s.drafts, s.archived, s.templates} {
+		for _, document := range documents {
+			if document.URLID != "" {
+				ids[document.URLID] = true
+			}
+		}
+	}
+	return ids
+}
+
+// countLinks counts the links of a text to other documents of the wiki, and
+// those of them pointing to a document that doesn't exist anymore.
+func countLinks(text string, known map[string]bool) (links, broken int) {
+	for _, match := range internalLink.FindAllStringSubmatch(text, -1) {
+		links++
+		if !known[match[1]] {
+			broken++
+		}
+	}
+	return links, broken
+}
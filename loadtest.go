@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type loadTestOptions struct {
+	collections int
+	documents   int
+	users       int
+	comments    int
+	textSize    int
+	scrapes     int
+	seed        int64
+	verbose     bool
+}
+
+type fixtures struct {
+	collections []Collection
+	documents   []Document
+	users       []User
+	comments    []Comment
+}
+
+var loadTestWords = []string{
+	"outline", "runbook", "deploy", "service", "incident", "database", "review", "team",
+	"process", "onboarding", "policy", "release", "backup", "monitoring", "design", "api",
+}
+
+// runLoadTest implements the loadtest subcommand: it serves synthetic Outline
+// API fixtures of the requested size from an in-process server and runs the
+// collector against them, with the configuration taken from the environment
+// as usual. It reports how long scrapes take, how many series they produce
+// and how much memory they need, so cardinality settings can be validated
+// against a wiki's projected growth before deploying them.
+func runLoadTest(args []string) int {
+	var opts loadTestOptions
+	flags := flag.NewFlagSet("loadtest", flag.ExitOnError)
+	flags.IntVar(&opts.collections, "collections", 50, "number of synthetic collections")
+	flags.IntVar(&opts.documents, "documents", 10000, "number of synthetic documents")
+	flags.IntVar(&opts.users, "users", 1000, "number of synthetic users")
+	flags.IntVar(&opts.comments, "comments", 5000, "number of synthetic comments")
+	flags.IntVar(&opts.textSize, "text-size", 2000, "approximate size of each document text in bytes")
+	flags.IntVar(&opts.scrapes, "scrapes", 2, "number of scrapes to run")
+	flags.Int64Var(&opts.seed, "seed", 1, "random seed for the fixtures")
+	flags.BoolVar(&opts.verbose, "verbose", false, "keep the exporter's logs")
+	flags.Parse(args)
+
+	if !opts.verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	fmt.Printf("Generating %d collections, %d documents, %d users, %d comments\n",
+		opts.collections, opts.documents, opts.users, opts.comments)
+	data := generateFixtures(rand.New(rand.NewSource(opts.seed)), opts)
+	server := httptest.NewServer(data.handler())
+	defer server.Close()
+
+	// The mock API is the only instance scraped, whatever the environment says.
+	os.Setenv("OUTLINE_API_KEY", "loadtest")
+	os.Unsetenv("OUTLINE_INSTANCES")
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return 1
+	}
+	config.OutlineAPIURL = server.URL
+	config.ScrapeInterval = 0
+	exporter := newExporter(config)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(exporter)
+
+	runtime.GC()
+	var baseline runtime.MemStats
+	runtime.ReadMemStats(&baseline)
+	fmt.Printf("Fixtures hold %s of heap\n", formatBytes(baseline.HeapAlloc))
+
+	for scrape := 1; scrape <= opts.scrapes; scrape++ {
+		stop := make(chan struct{})
+		peak := sampleHeap(stop)
+
+		var before runtime.MemStats
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		families, err := registry.Gather()
+		elapsed := time.Since(start)
+		close(stop)
+
+		var after runtime.MemStats
+		runtime.ReadMemStats(&after)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Scrape %d failed: %v\n", scrape, err)
+			return 1
+		}
+
+		series := 0
+		for _, family := range families {
+			series += len(family.GetMetric())
+		}
+		fmt.Printf("Scrape %d: %v, %d series in %d families, peak heap %s above fixtures, %s allocated\n",
+			scrape, elapsed.Round(time.Millisecond), series, len(families),
+			formatBytes(saturatingSub(<-peak, baseline.HeapAlloc)),
+			formatBytes(after.TotalAlloc-before.TotalAlloc))
+	}
+	return 0
+}
+
+// sampleHeap records the highest heap size seen until stop is closed.
+func sampleHeap(stop <-chan struct{}) <-chan uint64 {
+	result := make(chan uint64, 1)
+	go func() {
+		var peak uint64
+		var stats runtime.MemStats
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			runtime.ReadMemStats(&stats)
+			peak = max(peak, stats.HeapAlloc)
+			select {
+			case <-stop:
+				result <- peak
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return result
+}
+
+func generateFixtures(rng *rand.Rand, opts loadTestOptions) *fixtures {
+	now := time.Now()
+	randomTime := func(maxAge time.Duration) time.Time {
+		return now.Add(-time.Duration(rng.Int63n(int64(maxAge))))
+	}
+
+	data := &fixtures{}
+	for i := 0; i < opts.collections; i++ {
+		data.collections = append(data.collections, Collection{
+			ID:        fmt.Sprintf("collection-%d", i),
+			Name:      fmt.Sprintf("Collection %d", i),
+			CreatedAt: randomTime(3 * 365 * day),
+		})
+	}
+	for i := 0; i < opts.users; i++ {
+		user := User{
+			ID:        fmt.Sprintf("user-%d", i),
+			Name:      fmt.Sprintf("User %d", i),
+			CreatedAt: randomTime(3 * 365 * day),
+		}
+		if rng.Intn(10) > 0 {
+			user.LastActiveAt = randomTime(365 * day)
+		}
+		data.users = append(data.users, user)
+	}
+	for i := 0; i < opts.documents; i++ {
+		document := Document{
+			ID:        fmt.Sprintf("document-%d", i),
+			Title:     fmt.Sprintf("Document %d about %s", i, loadTestWords[rng.Intn(len(loadTestWords))]),
+			Text:      generateText(rng, opts.textSize, opts.users),
+			CreatedAt: randomTime(3 * 365 * day),
+			UpdatedAt: randomTime(365 * day),
+			Views:     rng.Intn(5000),
+			Revision:  1 + rng.Intn(200),
+		}
+		if opts.collections > 0 {
+			document.CollectionId = data.collections[rng.Intn(opts.collections)].ID
+		}
+		if rng.Intn(10) > 0 {
+			document.PublishedAt = document.CreatedAt
+		}
+		data.documents = append(data.documents, document)
+	}
+	for i := 0; i < opts.comments && opts.documents > 0; i++ {
+		comment := Comment{
+			ID:         fmt.Sprintf("comment-%d", i),
+			DocumentID: data.documents[rng.Intn(opts.documents)].ID,
+			CreatedAt:  randomTime(365 * day),
+		}
+		if i > 0 && rng.Intn(3) == 0 {
+			comment.ParentCommentID = data.comments[rng.Intn(i)].ID
+		}
+		if rng.Intn(2) == 0 {
+			comment.ResolvedAt = now
+		}
+		data.comments = append(data.comments, comment)
+	}
+	return data
+}
+
+func generateText(rng *rand.Rand, size, users int) string {
+	var text strings.Builder
+	if users > 0 && rng.Intn(2) == 0 {
+		fmt.Fprintf(&text, "Owner: User %d\n\n", rng.Intn(users))
+	}
+	for text.Len() < size {
+		word := loadTestWords[rng.Intn(len(loadTestWords))]
+		if rng.Intn(50) == 0 {
+			text.WriteString("#")
+		}
+		text.WriteString(word)
+		text.WriteString(" ")
+	}
+	return text.String()
+}
+
+func (f *fixtures) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/collections.list", func(w http.ResponseWriter, r *http.Request) {
+		servePage(w, r, requestParams(r), f.collections)
+	})
+	mux.HandleFunc("/api/documents.list", func(w http.ResponseWriter, r *http.Request) {
+		params := requestParams(r)
+		collectionID, _ := params["collectionId"].(string)
+		if collectionID == "" {
+			servePage(w, r, params, f.documents)
+			return
+		}
+		var documents []Document
+		for _, document := range f.documents {
+			if document.CollectionId == collectionID {
+				documents = append(documents, document)
+			}
+		}
+		servePage(w, r, params, documents)
+	})
+	mux.HandleFunc("/api/documents.search", func(w http.ResponseWriter, r *http.Request) {
+		params := requestParams(r)
+		query, _ := params["query"].(string)
+		var results []SearchResult
+		for _, document := range f.documents {
+			if strings.Contains(strings.ToLower(document.Title), strings.ToLower(query)) {
+				results = append(results, SearchResult{Document: document})
+			}
+		}
+		servePage(w, r, params, results)
+	})
+	mux.HandleFunc("/api/users.list", func(w http.ResponseWriter, r *http.Request) {
+		servePage(w, r, requestParams(r), f.users)
+	})
+	mux.HandleFunc("/api/comments.list", func(w http.ResponseWriter, r *http.Request) {
+		servePage(w, r, requestParams(r), f.comments)
+	})
+	// Endpoints without fixtures return empty lists.
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		servePage(w, r, requestParams(r), []struct{}{})
+	})
+	return mux
+}
+
+// requestParams merges the JSON body and the query string of a request, the
+// same way Outline accepts parameters from both.
+func requestParams(r *http.Request) map[string]any {
+	params := make(map[string]any)
+	json.NewDecoder(r.Body).Decode(&params)
+	for key, values := range r.URL.Query() {
+		params[key] = values[0]
+	}
+	return params
+}
+
+func servePage[T any](w http.ResponseWriter, r *http.Request, params map[string]any, items []T) {
+	limit, offset := intParam(params["limit"], 25), intParam(params["offset"], 0)
+	end := min(offset+limit, len(items))
+	page := []T{}
+	if offset < len(items) {
+		page = items[offset:end]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"data": page,
+		"pagination": Pagination{
+			Limit:    limit,
+			Offset:   offset,
+			NextPath: fmt.Sprintf("%s?limit=%d&offset=%d", r.URL.Path, limit, offset+limit),
+		},
+	})
+}
+
+func intParam(value any, fallback int) int {
+	switch v := value.(type) {
+	case float64:
+		return int(v)
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func saturatingSub(a, b uint64) uint64 {
+	if a < b {
+		return 0
+	}
+	return a - b
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+)
+
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// setupLogging installs the LOG_LEVEL and LOG_FORMAT logger as the default,
+// for log/slog as well as the log package used by libraries.
+func setupLogging(config Config) {
+	options := &slog.HandlerOptions{Level: logLevels[config.LogLevel]}
+	var handler slog.Handler = slog.NewTextHandler(os.Stderr, options)
+	if config.LogFormat == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+// newExporterLogger returns the logger of an exporter, which names the
+// instance when several are monitored.
+func newExporterLogger(config Config) *slog.Logger {
+	if config.InstanceName == "" {
+		return slog.Default()
+	}
+	return slog.Default().With("instance", config.InstanceName)
+}
+
+// errorLogger adapts the default logger for promhttp, which reports
+// failures of the metrics handler through Println.
+func errorLogger() *slogPrinter {
+	return &slogPrinter{}
+}
+
+type slogPrinter struct{}
+
+func (slogPrinter) Println(args ...any) {
+	slog.Error("Error serving metrics", "error", args)
+}
+
+// fatal logs an error and exits, the slog counterpart of log.Fatal.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -2,28 +2,133 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"net/http/httputil"
+	"net/url"
 	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/sync/errgroup"
 )
 
 type Config struct {
-	OutlineAPIURL string
-	OutlineAPIKey string
-	ListenAddress string
-	MetricsPath   string
-	ScrapeTimeout time.Duration
-	PageLimit     int
-	Debug         bool
+	Profile                 string
+	InstanceName            string
+	Instances               []Instance
+	OutlineAPIURL           string
+	OutlineAPIKeys          []string
+	OutlineTLS              *tls.Config
+	HTTPMaxIdleConns        int
+	HTTPMaxIdleConnsPerHost int
+	HTTPIdleConnTimeout     time.Duration
+	HTTPKeepAlives          bool
+	HTTPCompression         bool
+	ListenAddress           string
+	ListenTLSCert           string
+	ListenTLSKey            string
+	BasicAuthUsers          map[string]string
+	MetricsPath             string
+	OpenMetrics             bool
+	OpenMetricsCreated      bool
+	ScrapeTimeout           time.Duration
+	ScrapeInterval          time.Duration
+	CacheTTL                time.Duration
+	CacheStaleTTL           time.Duration
+	APIRateLimit            float64
+	APIRateBurst            int
+	CircuitBreakerFailures  int
+	CircuitBreakerCooldown  time.Duration
+	ConditionalRequests     bool
+	ConditionalRequestsAge  time.Duration
+	PageLimit               int
+	Debug                   bool
+	DebugUnsafe             bool
+	LogLevel                string
+	LogFormat               string
+	OwnerField              string
+	StaleAfter              time.Duration
+	StaleThresholds         []time.Duration
+	Location                *time.Location
+	CapabilityCheckInterval time.Duration
+	CompatibilityCheck      string
+	AgeMetrics              string
+	AdminToken              string
+	EnablePprof             bool
+	PprofAddress            string
+	ErrorHistorySize        int
+
+	DocumentActivityWindow     time.Duration
+	IncrementalScrape          bool
+	FullScrapeInterval         time.Duration
+	ViewsRateAlpha             float64
+	AttachmentSizes            bool
+	RevisionHistory            bool
+	RevisionConcurrency        int
+	RevisionDocumentsPerScrape int
+	PerDocumentMetrics         bool
+	DocumentTitleLabels        bool
+	ExcludeDocumentText        bool
+	PerUserMetrics             bool
+	DocumentSizeBuckets        []float64
+	DocumentTopN               int
+	DocumentTopNBy             []string
+	DocumentAgeBuckets         []time.Duration
+	UserActivityBuckets        []time.Duration
+	TagPattern                 string
+	SavedSearches              []SavedSearch
+
+	CollectionsInclude []string
+	CollectionsExclude []string
+	CollectionFilter   *collectionFilter
+
+	ProbeTargets map[string][]string
+
+	OutlineMetricsURL    string
+	OutlineMetricsPrefix string
+
+	ServeMetrics    bool
+	PushInterval    time.Duration
+	OTLPEndpoint    string
+	OTLPHeaders     map[string]string
+	OTLPServiceName string
+	TracesEndpoint  string
+
+	PushOnce            bool
+	PushgatewayURL      string
+	PushgatewayJob      string
+	PushgatewayGrouping map[string]string
+
+	RemoteWriteURL         string
+	RemoteWriteUsername    string
+	RemoteWritePassword    string
+	RemoteWriteBearerToken string
+	RemoteWriteLabels      map[string]string
+
+	// Collectors holds whether each collector is enabled by configuration.
+	Collectors map[string]bool
+}
+
+// SavedSearch is a named query run against documents.search on every scrape.
+type SavedSearch struct {
+	Name  string
+	Query string
 }
 
 type Collection struct {
@@ -32,20 +137,41 @@ type Collection struct {
 	Description string    `json:"description"`
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
+	Permission  string    `json:"permission"`
+	Private     bool      `json:"private" schema:"optional"`
+	Sharing     bool      `json:"sharing"`
 }
 
 type Document struct {
-	ID           string    `json:"id"`
-	Title        string    `json:"title"`
-	Text         string    `json:"text"`
-	CreatedAt    time.Time `json:"createdAt"`
-	UpdatedAt    time.Time `json:"updatedAt"`
-	PublishedAt  time.Time `json:"publishedAt"`
-	ArchivedAt   time.Time `json:"archivedAt,omitempty"`
-	DeletedAt    time.Time `json:"deletedAt,omitempty"`
-	Views        int       `json:"views"`
-	Revision     int       `json:"revision"`
-	CollectionId string    `json:"collectionId"`
+	ID               string    `json:"id"`
+	URL              string    `json:"url"`
+	URLID            string    `json:"urlId"`
+	Title            string    `json:"title"`
+	Text             string    `json:"text"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+	PublishedAt      time.Time `json:"publishedAt"`
+	ArchivedAt       time.Time `json:"archivedAt,omitempty"`
+	DeletedAt        time.Time `json:"deletedAt,omitempty"`
+	Views            int       `json:"views"`
+	Revision         int       `json:"revision"`
+	CollectionId     string    `json:"collectionId"`
+	CreatedBy        UserRef   `json:"createdBy"`
+	Template         bool      `json:"template"`
+	ParentDocumentID string    `json:"parentDocumentId"`
+	Tasks            *struct {
+		Completed int `json:"completed"`
+		Total     int `json:"total"`
+	} `json:"tasks" schema:"optional"`
+	// TextSize is the length of Text, kept when EXCLUDE_DOCUMENT_TEXT drops
+	// the text itself.
+	TextSize int `json:"-"`
+}
+
+// UserRef is the summary of a user embedded in other objects.
+type UserRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
 }
 
 type User struct {
@@ -53,6 +179,59 @@ type User struct {
 	Name         string    `json:"name"`
 	CreatedAt    time.Time `json:"createdAt"`
 	LastActiveAt time.Time `json:"lastActiveAt"`
+	Role         string    `json:"role" schema:"optional"`
+	IsAdmin      bool      `json:"isAdmin" schema:"optional"`
+	IsViewer     bool      `json:"isViewer" schema:"optional"`
+	IsSuspended  bool      `json:"isSuspended"`
+}
+
+type Comment struct {
+	ID              string    `json:"id"`
+	DocumentID      string    `json:"documentId"`
+	ParentCommentID string    `json:"parentCommentId"`
+	CreatedAt       time.Time `json:"createdAt"`
+	ResolvedAt      time.Time `json:"resolvedAt" schema:"optional"`
+}
+
+type Group struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	MemberCount int       `json:"memberCount"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type Share struct {
+	ID         string    `json:"id"`
+	DocumentID string    `json:"documentId"`
+	Published  bool      `json:"published"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type Star struct {
+	ID           string    `json:"id"`
+	DocumentID   string    `json:"documentId"`
+	CollectionID string    `json:"collectionId"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type Revision struct {
+	ID         string    `json:"id"`
+	DocumentID string    `json:"documentId"`
+	CreatedAt  time.Time `json:"createdAt"`
+	CreatedBy  UserRef   `json:"createdBy"`
+}
+
+type FileOperation struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	State     string    `json:"state"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type SearchResult struct {
+	Ranking  float64  `json:"ranking"`
+	Context  string   `json:"context"`
+	Document Document `json:"document"`
 }
 
 type Pagination struct {
@@ -61,51 +240,341 @@ type Pagination struct {
 	NextPath string `json:"nextPath"`
 }
 
+// apiError is returned for non-200 responses so callers can react to
+// specific status codes.
+type apiError struct {
+	StatusCode int
+	Body       string
+	// RetryAfter is the delay Outline asked for in a Retry-After header.
+	RetryAfter time.Duration
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Body)
+}
+
 type apiResp[T any] struct {
 	Data       []T        `json:"data"`
 	Pagination Pagination `json:"pagination"`
 }
 
 type Exporter struct {
-	config Config
-
-	up                       *prometheus.Desc
-	scrapeSuccessTimestamp   *prometheus.Desc
-	scrapeErrorsTotal        prometheus.Counter
-	scrapeDurationSeconds    prometheus.Gauge
-	collectionsTotal         *prometheus.Desc
-	collectionDocumentsCount *prometheus.Desc
-	collectionAge            *prometheus.Desc
-	documentsTotal           *prometheus.Desc
-	documentRevisions        *prometheus.Desc
-	documentViews            *prometheus.Desc
-	documentAge              *prometheus.Desc
-	documentSize             *prometheus.Desc
-	documentUpdateAge        *prometheus.Desc
-	usersTotal               *prometheus.Desc
-	userLastActive           *prometheus.Desc
-	userAge                  *prometheus.Desc
+	config  Config
+	content *contentAnalyzer
+	keys    *keyPool
+	// ctx is cancelled on shutdown, aborting the background requests.
+	// Requests made on demand for a scrape use the context of the scrape.
+	ctx context.Context
+	// client is shared by all requests so connections are reused.
+	client   *http.Client
+	errors   *errorLog
+	statuses *statusLog
+	logger   *slog.Logger
+
+	mu                  sync.Mutex
+	refreshMu           sync.Mutex
+	warned              map[string]bool
+	previous            *snapshot
+	latest              *snapshot
+	ready               chan struct{}
+	readyOnce           sync.Once
+	lastViewed          map[string]time.Time
+	documentViewsRate   map[string]float64
+	collectionViewsRate map[string]float64
+	generation          uint64
+	serverVersion       string
+	collectors          map[string]*collectorStatus
+	events              eventCursor
+	// responses is nil unless CONDITIONAL_REQUESTS is enabled.
+	responses *responseCache
+	// limiter is nil unless API_RATE_LIMIT is set.
+	limiter *rateLimiter
+	// breaker is nil when CIRCUIT_BREAKER_FAILURES is 0.
+	breaker         *circuitBreaker
+	inventory       documentInventory
+	attachmentSizes map[string]int64
+	revisions       map[string]revisionHistory
+	// allowedCollections are the IDs of the collections the filter allows,
+	// nil until they were resolved.
+	allowedCollections map[string]bool
+
+	up                                    *prometheus.Desc
+	scrapeSuccessTimestamp                *prometheus.Desc
+	scrapeErrorsTotal                     *prometheus.CounterVec
+	commentsCreated                       prometheus.Counter
+	scrapeDurationSeconds                 prometheus.Gauge
+	collectionDocumentsAdded              *prometheus.CounterVec
+	collectionDocumentsRemoved            *prometheus.CounterVec
+	entitiesDeleted                       *prometheus.CounterVec
+	clockSkewDetections                   *prometheus.CounterVec
+	scrapeGeneration                      *prometheus.Desc
+	refreshDuration                       *prometheus.Desc
+	stageDuration                         *prometheus.Desc
+	pagesFetched                          *prometheus.Desc
+	itemsFetched                          *prometheus.Desc
+	serverInfo                            *prometheus.Desc
+	apiCompatible                         *prometheus.Desc
+	collectorSupported                    *prometheus.Desc
+	collectorEnabled                      *prometheus.Desc
+	lastErrorTimestamp                    *prometheus.Desc
+	cacheAge                              *prometheus.Desc
+	circuitBreakerState                   *prometheus.Desc
+	schemaWarnings                        *prometheus.CounterVec
+	apiKeyRequests                        *prometheus.CounterVec
+	apiConnections                        *prometheus.CounterVec
+	apiKeyErrors                          *prometheus.CounterVec
+	apiRequests                           *prometheus.CounterVec
+	apiRequestFailures                    *prometheus.CounterVec
+	apiRateLimit                          *prometheus.GaugeVec
+	apiRateLimitRemaining                 *prometheus.GaugeVec
+	apiRateLimitReset                     *prometheus.GaugeVec
+	eventsTotal                           *prometheus.CounterVec
+	cacheItems                            *prometheus.Desc
+	cacheBytes                            *prometheus.Desc
+	collectionsTotal                      *prometheus.Desc
+	collectionDocumentsCount              *prometheus.Desc
+	collectionAge                         *prometheus.Desc
+	collectionFreshestDocumentAge         *prometheus.Desc
+	collectionCreatedTimestamp            *prometheus.Desc
+	collectionLastDocumentUpdateTimestamp *prometheus.Desc
+	documentsTotal                        *prometheus.Desc
+	documentsStaleTotal                   *prometheus.Desc
+	documentRevisions                     *prometheus.Desc
+	documentViews                         *prometheus.Desc
+	documentAge                           *prometheus.Desc
+	documentSize                          *prometheus.Desc
+	documentUpdateAge                     *prometheus.Desc
+	documentWords                         *prometheus.Desc
+	collectionWords                       *prometheus.Desc
+	documentSizeHistogram                 *prometheus.Desc
+	documentUpdateAgeHistogram            *prometheus.Desc
+	documentTasks                         *prometheus.Desc
+	documentTasksCompleted                *prometheus.Desc
+	documentImages                        *prometheus.Desc
+	documentAttachments                   *prometheus.Desc
+	documentInternalLinks                 *prometheus.Desc
+	documentBrokenLinks                   *prometheus.Desc
+	internalLinksTotal                    *prometheus.Desc
+	brokenLinksTotal                      *prometheus.Desc
+	documentCreatedTimestamp              *prometheus.Desc
+	documentUpdatedTimestamp              *prometheus.Desc
+	documentLastRevisionAge               *prometheus.Desc
+	documentLastRevisionTimestamp         *prometheus.Desc
+	documentPublishedTimestamp            *prometheus.Desc
+	documentPublished                     *prometheus.Desc
+	documentArchivedTimestamp             *prometheus.Desc
+	documentDeletedTimestamp              *prometheus.Desc
+	usersTotal                            *prometheus.Desc
+	usersByRole                           *prometheus.Desc
+	usersSuspended                        *prometheus.Desc
+	usersInvited                          *prometheus.Desc
+	oldestInviteAge                       *prometheus.Desc
+	userLastActive                        *prometheus.Desc
+	userAge                               *prometheus.Desc
+	userLastActiveTimestamp               *prometheus.Desc
+	userCreatedTimestamp                  *prometheus.Desc
+	usersLastActive                       *prometheus.Desc
+	ownerDocumentsCount                   *prometheus.Desc
+	documentsCreatedBy                    *prometheus.Desc
+	ownerStaleDocumentsCount              *prometheus.Desc
+	documentTags                          *prometheus.Desc
+	tagDocumentsCount                     *prometheus.Desc
+	tagCollectionDocumentsCount           *prometheus.Desc
+	savedSearchResults                    *prometheus.Desc
+	commentsTotal                         *prometheus.Desc
+	commentMessages                       *prometheus.Desc
+	groupsTotal                           *prometheus.Desc
+	groupMembers                          *prometheus.Desc
+	groupAge                              *prometheus.Desc
+	groupCreatedTimestamp                 *prometheus.Desc
+	attachmentsTotal                      *prometheus.Desc
+	collectionAttachments                 *prometheus.Desc
+	attachmentsBytes                      *prometheus.Desc
+	collectionAttachmentsBytes            *prometheus.Desc
+	draftsTotal                           *prometheus.Desc
+	documentsPublishedTotal               *prometheus.Desc
+	documentsUnpublishedTotal             *prometheus.Desc
+	userDrafts                            *prometheus.Desc
+	archivedTotal                         *prometheus.Desc
+	deletedTotal                          *prometheus.Desc
+	templatesTotal                        *prometheus.Desc
+	collectionTemplates                   *prometheus.Desc
+	templateUpdateAge                     *prometheus.Desc
+	templateUpdatedTimestamp              *prometheus.Desc
+	starsTotal                            *prometheus.Desc
+	documentStars                         *prometheus.Desc
+	teamInfo                              *prometheus.Desc
+	sharesTotal                           *prometheus.Desc
+	fileOperations                        *prometheus.Desc
+	fileOperationOldestIncomplete         *prometheus.Desc
+	sharePublished                        *prometheus.Desc
+	shareAge                              *prometheus.Desc
+	shareCreatedTimestamp                 *prometheus.Desc
+	documentComments                      *prometheus.Desc
+	aggregatedDocumentsCount              *prometheus.Desc
+	aggregatedDocumentViews               *prometheus.Desc
+	aggregatedDocumentSize                *prometheus.Desc
+	aggregatedDocumentRevisions           *prometheus.Desc
+	aggregatedDocumentAge                 *prometheus.Desc
+	aggregatedDocumentUpdateAge           *prometheus.Desc
+	documentViewsRateDesc                 *prometheus.Desc
+	collectionViewsRateDesc               *prometheus.Desc
+	collectionPrivate                     *prometheus.Desc
+	collectionPermission                  *prometheus.Desc
+	collectionSharing                     *prometheus.Desc
+	collectionMembers                     *prometheus.Desc
+	collectionGroups                      *prometheus.Desc
+	collectionRootDocuments               *prometheus.Desc
+	collectionMaxDepth                    *prometheus.Desc
+	collectionAvgDepth                    *prometheus.Desc
 }
 
 func newExporter(config Config) *Exporter {
+	documentLabels := documentLabelNames(config)
 	return &Exporter{
-		config: config,
+		config:   config,
+		ctx:      context.Background(),
+		content:  newContentAnalyzer(config),
+		errors:   newErrorLog(config.ErrorHistorySize, config.InstanceName),
+		statuses: newStatusLog(),
+		logger:   newExporterLogger(config),
+		keys:     newKeyPool(config.OutlineAPIKeys),
+		client:   &http.Client{Timeout: config.ScrapeTimeout, Transport: newTransport(config)},
+		ready:    make(chan struct{}),
+
+		attachmentSizes: make(map[string]int64),
+		responses:       newResponseCache(config),
+		limiter:         newRateLimiter(config),
+		breaker:         newCircuitBreaker(config),
+		revisions:       make(map[string]revisionHistory),
+		scrapeGeneration: prometheus.NewDesc(
+			"outline_scrape_generation",
+			"Sequence number of the snapshot the metrics were built from",
+			nil, nil),
+		refreshDuration: prometheus.NewDesc(
+			"outline_scrape_refresh_duration_seconds",
+			"Time it took to fetch the snapshot the metrics were built from",
+			nil, nil),
+		stageDuration: prometheus.NewDesc(
+			"outline_scrape_stage_duration_seconds",
+			"Time it took to fetch each resource of the snapshot the metrics were built from",
+			[]string{"stage"}, nil),
+		pagesFetched: prometheus.NewDesc(
+			"outline_scrape_pages_fetched",
+			"Pages of list endpoints fetched for each resource of the snapshot the metrics were built from",
+			[]string{"resource"}, nil),
+		itemsFetched: prometheus.NewDesc(
+			"outline_scrape_items_fetched",
+			"Items returned by list endpoints for each resource of the snapshot the metrics were built from",
+			[]string{"resource"}, nil),
+		serverInfo: prometheus.NewDesc(
+			"outline_server_info",
+			"Version of the Outline server, always 1",
+			[]string{"version"}, nil),
+		apiCompatible: prometheus.NewDesc(
+			"outline_api_compatible",
+			"Whether the Outline server version is within the range this exporter was tested against",
+			[]string{"version", "min_version", "max_version"}, nil),
+		collectorSupported: prometheus.NewDesc(
+			"outline_collector_supported",
+			"Whether the API endpoint of a collector is available on the Outline instance",
+			[]string{"collector"}, nil),
+		collectorEnabled: prometheus.NewDesc(
+			"outline_collector_enabled",
+			"Whether a collector is running, with the reason when it was disabled",
+			[]string{"collector", "reason"}, nil),
+		eventsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "outline_events_total",
+			Help: "Events recorded by Outline since the exporter started, by event name",
+		}, []string{"name"}),
+		apiKeyRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "outline_api_key_requests_total",
+			Help: "Requests sent to the Outline API per configured key",
+		}, []string{"key"}),
+		apiConnections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "outline_api_connections_total",
+			Help: "Connections used for Outline API requests, by whether an idle connection was reused",
+		}, []string{"reused"}),
+		apiKeyErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "outline_api_key_errors_total",
+			Help: "Failed Outline API requests per configured key and HTTP status",
+		}, []string{"key", "status"}),
+		apiRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "outline_api_requests_total",
+			Help: "Requests sent to the Outline API, by endpoint and HTTP status code",
+		}, []string{"endpoint", "code"}),
+		apiRequestFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "outline_api_request_failures_total",
+			Help: "Outline API requests that failed after all retries, by endpoint",
+		}, []string{"endpoint"}),
+		apiRateLimit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "outline_api_rate_limit",
+			Help: "Requests allowed per rate limit window, from the RateLimit-Limit header, per configured key",
+		}, []string{"key"}),
+		apiRateLimitRemaining: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "outline_api_rate_limit_remaining",
+			Help: "Requests left in the current rate limit window, from the RateLimit-Remaining header, per configured key",
+		}, []string{"key"}),
+		apiRateLimitReset: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "outline_api_rate_limit_reset_timestamp_seconds",
+			Help: "Unix timestamp at which the current rate limit window resets, from the RateLimit-Reset header, per configured key",
+		}, []string{"key"}),
+		schemaWarnings: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "outline_api_schema_warnings_total",
+			Help: "Total number of API responses missing a field the exporter relies on",
+		}, []string{"endpoint", "field"}),
+		cacheAge: prometheus.NewDesc(
+			"outline_scrape_cache_age_seconds",
+			"Time since the snapshot the metrics were built from was taken",
+			nil, nil),
+		circuitBreakerState: prometheus.NewDesc(
+			"outline_circuit_breaker_state",
+			"Current state of the circuit breaker suspending Outline API requests, 1 for the active state",
+			[]string{"state"}, nil),
+		lastErrorTimestamp: prometheus.NewDesc(
+			"outline_exporter_last_error_timestamp",
+			"Unix timestamp of the last failed Outline API request",
+			nil, nil),
+		cacheItems: prometheus.NewDesc(
+			"outline_exporter_cache_items",
+			"Number of items held in the retained snapshot",
+			[]string{"type"}, nil),
+		cacheBytes: prometheus.NewDesc(
+			"outline_exporter_cache_bytes",
+			"Estimated memory held by the retained snapshot in bytes",
+			nil, nil),
 		up: prometheus.NewDesc(
 			"outline_up",
-			"Was the last Outline scrape successful",
-			nil, nil),
+			"Was the last Outline scrape successful, per resource and overall when the resource is empty",
+			[]string{"resource"}, nil),
 		scrapeSuccessTimestamp: prometheus.NewDesc(
 			"outline_scrape_success_timestamp",
 			"Timestamp of the last successful scrape",
 			nil, nil),
-		scrapeErrorsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+		scrapeErrorsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name: "outline_scrape_errors_total",
-			Help: "Total number of scrape errors",
-		}),
+			Help: "Total number of scrape errors, by error class",
+		}, []string{"class"}),
 		scrapeDurationSeconds: prometheus.NewGauge(prometheus.GaugeOpts{
 			Name: "outline_scrape_duration_seconds",
 			Help: "Duration of the scrape",
 		}),
+		collectionDocumentsAdded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "outline_collection_documents_added_total",
+			Help: "Total number of documents that appeared in a collection between scrapes",
+		}, []string{"collection_id", "collection_name"}),
+		collectionDocumentsRemoved: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "outline_collection_documents_removed_total",
+			Help: "Total number of documents that disappeared from a collection between scrapes",
+		}, []string{"collection_id", "collection_name"}),
+		entitiesDeleted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "outline_entities_deleted_total",
+			Help: "Total number of documents, users or collections that disappeared between scrapes",
+		}, []string{"type"}),
+		clockSkewDetections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "outline_clock_skew_detections_total",
+			Help: "Total number of timestamps found in the future and clamped to an age of zero",
+		}, []string{"type"}),
 		collectionsTotal: prometheus.NewDesc(
 			"outline_collections_total",
 			"Total number of collections",
@@ -118,30 +587,142 @@ func newExporter(config Config) *Exporter {
 			"outline_collection_age_seconds",
 			"Age of collection in seconds",
 			[]string{"collection_id", "collection_name"}, nil),
+		collectionFreshestDocumentAge: prometheus.NewDesc(
+			"outline_collection_freshest_document_age_seconds",
+			"Time since the most recently updated document of a collection was updated in seconds",
+			[]string{"collection_id", "collection_name"}, nil),
+		collectionCreatedTimestamp: prometheus.NewDesc(
+			"outline_collection_created_timestamp_seconds",
+			"Unix timestamp at which the collection was created",
+			[]string{"collection_id", "collection_name"}, nil),
+		collectionLastDocumentUpdateTimestamp: prometheus.NewDesc(
+			"outline_collection_last_document_update_timestamp_seconds",
+			"Unix timestamp of the most recent document update in a collection",
+			[]string{"collection_id", "collection_name"}, nil),
 		documentsTotal: prometheus.NewDesc(
 			"outline_documents_total",
 			"Total number of documents",
 			nil, nil),
+		documentsStaleTotal: prometheus.NewDesc(
+			"outline_documents_stale_total",
+			"Number of documents not updated within the threshold",
+			[]string{"threshold"}, nil),
 		documentRevisions: prometheus.NewDesc(
 			"outline_document_revisions",
 			"Number of revisions for a document",
-			[]string{"document_id", "collection_id"}, nil),
+			documentLabels, nil),
 		documentViews: prometheus.NewDesc(
 			"outline_document_views",
 			"Number of views for a document",
-			[]string{"document_id", "collection_id"}, nil),
+			documentLabels, nil),
 		documentAge: prometheus.NewDesc(
 			"outline_document_age_seconds",
 			"Age of document in seconds",
-			[]string{"document_id", "collection_id"}, nil),
+			documentLabels, nil),
 		documentSize: prometheus.NewDesc(
 			"outline_document_size_bytes",
 			"Size of document text in bytes",
-			[]string{"document_id", "collection_id"}, nil),
+			documentLabels, nil),
+		documentWords: prometheus.NewDesc(
+			"outline_document_words",
+			"Number of words in the document text, without markdown syntax, links and embedded data",
+			documentLabels, nil),
+		collectionWords: prometheus.NewDesc(
+			"outline_collection_words",
+			"Number of words in the documents of a collection",
+			[]string{"collection_id", "collection_name"}, nil),
+		documentTasks: prometheus.NewDesc(
+			"outline_document_tasks_total",
+			"Number of checklist items in the document",
+			documentLabels, nil),
+		documentTasksCompleted: prometheus.NewDesc(
+			"outline_document_tasks_completed",
+			"Number of checked checklist items in the document",
+			documentLabels, nil),
+		documentImages: prometheus.NewDesc(
+			"outline_document_images",
+			"Number of images embedded in the document, uploaded or external",
+			documentLabels, nil),
+		documentAttachments: prometheus.NewDesc(
+			"outline_document_attachments",
+			"Number of distinct uploaded files and images referenced by the document",
+			documentLabels, nil),
+		documentSizeHistogram: prometheus.NewDesc(
+			"outline_collection_document_size_bytes",
+			"Distribution of the text size of the documents of a collection in bytes",
+			[]string{"collection_id", "collection_name"}, nil),
+		documentUpdateAgeHistogram: prometheus.NewDesc(
+			"outline_collection_document_update_age_seconds",
+			"Distribution of the time since the documents of a collection were last updated",
+			[]string{"collection_id", "collection_name"}, nil),
+		documentInternalLinks: prometheus.NewDesc(
+			"outline_document_internal_links",
+			"Number of links from the document to other documents of the wiki",
+			documentLabels, nil),
+		documentBrokenLinks: prometheus.NewDesc(
+			"outline_document_broken_internal_links",
+			"Number of links from the document to documents that don't exist",
+			documentLabels, nil),
+		internalLinksTotal: prometheus.NewDesc(
+			"outline_internal_links_total",
+			"Number of links between documents of the wiki",
+			nil, nil),
+		brokenLinksTotal: prometheus.NewDesc(
+			"outline_broken_internal_links_total",
+			"Number of links to documents that don't exist",
+			nil, nil),
 		documentUpdateAge: prometheus.NewDesc(
 			"outline_document_update_age_seconds",
 			"Time since last document update in seconds",
-			[]string{"document_id", "collection_id"}, nil),
+			documentLabels, nil),
+		documentCreatedTimestamp: prometheus.NewDesc(
+			"outline_document_created_timestamp_seconds",
+			"Unix timestamp at which the document was created",
+			documentLabels, nil),
+		documentUpdatedTimestamp: prometheus.NewDesc(
+			"outline_document_updated_timestamp_seconds",
+			"Unix timestamp of the last document update",
+			documentLabels, nil),
+		documentLastRevisionAge: prometheus.NewDesc(
+			"outline_document_last_revision_age_seconds",
+			"Time since the latest revision of the document was saved",
+			documentLabelNames(config, "author"), nil),
+		documentLastRevisionTimestamp: prometheus.NewDesc(
+			"outline_document_last_revision_timestamp_seconds",
+			"Unix timestamp at which the latest revision of the document was saved",
+			documentLabelNames(config, "author"), nil),
+		documentPublishedTimestamp: prometheus.NewDesc(
+			"outline_document_published_timestamp_seconds",
+			"Unix timestamp at which the document was published",
+			documentLabels, nil),
+		documentPublished: prometheus.NewDesc(
+			"outline_document_published",
+			"Whether the document is published (1) or a draft (0)",
+			documentLabels, nil),
+		documentArchivedTimestamp: prometheus.NewDesc(
+			"outline_document_archived_timestamp_seconds",
+			"Unix timestamp at which the document was archived",
+			documentLabels, nil),
+		documentDeletedTimestamp: prometheus.NewDesc(
+			"outline_document_deleted_timestamp_seconds",
+			"Unix timestamp at which the document was deleted",
+			documentLabels, nil),
+		usersByRole: prometheus.NewDesc(
+			"outline_users_by_role",
+			"Number of users by role",
+			[]string{"role"}, nil),
+		usersSuspended: prometheus.NewDesc(
+			"outline_users_suspended_total",
+			"Number of suspended users",
+			nil, nil),
+		usersInvited: prometheus.NewDesc(
+			"outline_users_invited_total",
+			"Number of invited users who haven't signed in yet",
+			nil, nil),
+		oldestInviteAge: prometheus.NewDesc(
+			"outline_user_oldest_invite_age_seconds",
+			"Time since the oldest pending invitation was sent, 0 when there is none",
+			nil, nil),
 		usersTotal: prometheus.NewDesc(
 			"outline_users_total",
 			"Total number of users",
@@ -154,113 +735,539 @@ func newExporter(config Config) *Exporter {
 			"outline_user_age_seconds",
 			"Age of user account in seconds",
 			[]string{"user_id", "user_name"}, nil),
+		usersLastActive: prometheus.NewDesc(
+			"outline_users_last_active_seconds",
+			"Distribution of the time since users were last active in seconds",
+			nil, nil),
+		userLastActiveTimestamp: prometheus.NewDesc(
+			"outline_user_last_active_timestamp_seconds",
+			"Unix timestamp at which the user was last active",
+			[]string{"user_id", "user_name"}, nil),
+		userCreatedTimestamp: prometheus.NewDesc(
+			"outline_user_created_timestamp_seconds",
+			"Unix timestamp at which the user account was created",
+			[]string{"user_id", "user_name"}, nil),
+		documentsCreatedBy: prometheus.NewDesc(
+			"outline_documents_created_by",
+			"Number of documents created by a user",
+			[]string{"user_id", "user_name"}, nil),
+		ownerDocumentsCount: prometheus.NewDesc(
+			"outline_owner_documents_count",
+			"Number of documents declaring an owner",
+			[]string{"owner"}, nil),
+		ownerStaleDocumentsCount: prometheus.NewDesc(
+			"outline_owner_stale_documents_count",
+			"Number of stale documents declaring an owner",
+			[]string{"owner"}, nil),
+		documentTags: prometheus.NewDesc(
+			"outline_document_tags",
+			"Tags found in the document text, always 1",
+			[]string{"document_id", "tag"}, nil),
+		tagDocumentsCount: prometheus.NewDesc(
+			"outline_tag_documents_count",
+			"Number of documents carrying a tag",
+			[]string{"tag"}, nil),
+		tagCollectionDocumentsCount: prometheus.NewDesc(
+			"outline_tag_collection_documents_count",
+			"Number of documents carrying a tag in a collection",
+			[]string{"tag", "collection_id"}, nil),
+		commentsTotal: prometheus.NewDesc(
+			"outline_comments_total",
+			"Number of comment threads by resolution state",
+			[]string{"state"}, nil),
+		commentMessages: prometheus.NewDesc(
+			"outline_comment_messages_total",
+			"Number of comments including replies",
+			nil, nil),
+		commentsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "outline_comments_created_total",
+			Help: "Total number of comments and replies that appeared between scrapes",
+		}),
+		documentComments: prometheus.NewDesc(
+			"outline_document_comments",
+			"Number of comment threads on a document by resolution state",
+			documentLabelNames(config, "state"), nil),
+		groupsTotal: prometheus.NewDesc(
+			"outline_groups_total",
+			"Total number of groups",
+			nil, nil),
+		groupMembers: prometheus.NewDesc(
+			"outline_group_members",
+			"Number of members of a group",
+			[]string{"group_id", "group_name"}, nil),
+		groupAge: prometheus.NewDesc(
+			"outline_group_age_seconds",
+			"Time since the group was created",
+			[]string{"group_id", "group_name"}, nil),
+		groupCreatedTimestamp: prometheus.NewDesc(
+			"outline_group_created_timestamp_seconds",
+			"Unix timestamp of the group creation",
+			[]string{"group_id", "group_name"}, nil),
+		draftsTotal: prometheus.NewDesc(
+			"outline_documents_drafts_total",
+			"Number of unpublished draft documents",
+			nil, nil),
+		documentsPublishedTotal: prometheus.NewDesc(
+			"outline_documents_published_total",
+			"Number of published documents",
+			nil, nil),
+		documentsUnpublishedTotal: prometheus.NewDesc(
+			"outline_documents_unpublished_total",
+			"Number of documents that were never published or were unpublished",
+			nil, nil),
+		userDrafts: prometheus.NewDesc(
+			"outline_user_drafts",
+			"Number of unpublished draft documents created by a user",
+			[]string{"user_id", "user_name"}, nil),
+		archivedTotal: prometheus.NewDesc(
+			"outline_documents_archived_total",
+			"Number of archived documents",
+			nil, nil),
+		deletedTotal: prometheus.NewDesc(
+			"outline_documents_deleted_total",
+			"Number of deleted documents still in the trash",
+			nil, nil),
+		templatesTotal: prometheus.NewDesc(
+			"outline_templates_total",
+			"Total number of templates",
+			nil, nil),
+		collectionTemplates: prometheus.NewDesc(
+			"outline_collection_templates",
+			"Number of templates in a collection",
+			[]string{"collection_id", "collection_name"}, nil),
+		templateUpdateAge: prometheus.NewDesc(
+			"outline_template_update_age_seconds",
+			"Time since the template was last updated",
+			[]string{"template_id", "collection_id"}, nil),
+		templateUpdatedTimestamp: prometheus.NewDesc(
+			"outline_template_updated_timestamp_seconds",
+			"Unix timestamp of the last template update",
+			[]string{"template_id", "collection_id"}, nil),
+		teamInfo: prometheus.NewDesc(
+			"outline_team_info",
+			"Workspace the API key belongs to, always 1",
+			[]string{"team_id", "team_name", "subdomain", "sharing", "user_id"}, nil),
+		attachmentsTotal: prometheus.NewDesc(
+			"outline_attachments_total",
+			"Number of distinct attachments referenced by documents",
+			nil, nil),
+		collectionAttachments: prometheus.NewDesc(
+			"outline_collection_attachments",
+			"Number of distinct attachments referenced by the documents of a collection",
+			[]string{"collection_id", "collection_name"}, nil),
+		attachmentsBytes: prometheus.NewDesc(
+			"outline_attachments_bytes",
+			"Total size of the attachments referenced by documents",
+			nil, nil),
+		collectionAttachmentsBytes: prometheus.NewDesc(
+			"outline_collection_attachments_bytes",
+			"Total size of the attachments referenced by the documents of a collection",
+			[]string{"collection_id", "collection_name"}, nil),
+		sharesTotal: prometheus.NewDesc(
+			"outline_shares_total",
+			"Number of share links by published state",
+			[]string{"published"}, nil),
+		sharePublished: prometheus.NewDesc(
+			"outline_share_published",
+			"Whether a share link is published to the internet",
+			[]string{"share_id", "document_id"}, nil),
+		shareAge: prometheus.NewDesc(
+			"outline_share_age_seconds",
+			"Time since the share link was created",
+			[]string{"share_id", "document_id"}, nil),
+		shareCreatedTimestamp: prometheus.NewDesc(
+			"outline_share_created_timestamp_seconds",
+			"Unix timestamp of the share link creation",
+			[]string{"share_id", "document_id"}, nil),
+		starsTotal: prometheus.NewDesc(
+			"outline_stars_total",
+			"Total number of stars on documents and collections",
+			nil, nil),
+		documentStars: prometheus.NewDesc(
+			"outline_document_stars",
+			"Number of stars on a document",
+			[]string{"document_id"}, nil),
+		fileOperations: prometheus.NewDesc(
+			"outline_file_operations",
+			"Number of export and import jobs by type and state",
+			[]string{"type", "state"}, nil),
+		fileOperationOldestIncomplete: prometheus.NewDesc(
+			"outline_file_operation_oldest_incomplete_age_seconds",
+			"Time since the oldest export or import job that hasn't completed was created, 0 when there is none",
+			[]string{"type"}, nil),
+		savedSearchResults: prometheus.NewDesc(
+			"outline_saved_search_results",
+			"Number of documents matching a saved search",
+			[]string{"search"}, nil),
+		documentViewsRateDesc: prometheus.NewDesc(
+			"outline_document_views_rate",
+			"Exponentially weighted moving average of views per hour of a document",
+			documentLabels, nil),
+		collectionPrivate: prometheus.NewDesc(
+			"outline_collection_private",
+			"Whether only members can access the collection",
+			[]string{"collection_id", "collection_name"}, nil),
+		collectionPermission: prometheus.NewDesc(
+			"outline_collection_permission",
+			"Default permission of workspace members on the collection, always 1",
+			[]string{"collection_id", "collection_name", "permission"}, nil),
+		collectionSharing: prometheus.NewDesc(
+			"outline_collection_sharing",
+			"Whether documents of the collection can be shared publicly",
+			[]string{"collection_id", "collection_name"}, nil),
+		collectionMembers: prometheus.NewDesc(
+			"outline_collection_members_count",
+			"Number of users given access to the collection individually",
+			[]string{"collection_id", "collection_name"}, nil),
+		collectionGroups: prometheus.NewDesc(
+			"outline_collection_groups_count",
+			"Number of groups given access to the collection",
+			[]string{"collection_id", "collection_name"}, nil),
+		collectionRootDocuments: prometheus.NewDesc(
+			"outline_collection_root_documents",
+			"Number of documents at the root of a collection",
+			[]string{"collection_id", "collection_name"}, nil),
+		collectionMaxDepth: prometheus.NewDesc(
+			"outline_collection_max_depth",
+			"Nesting depth of the most deeply nested document of a collection, 1 for root documents",
+			[]string{"collection_id", "collection_name"}, nil),
+		collectionAvgDepth: prometheus.NewDesc(
+			"outline_collection_avg_depth",
+			"Average nesting depth of the documents of a collection, 1 for root documents",
+			[]string{"collection_id", "collection_name"}, nil),
+		collectionViewsRateDesc: prometheus.NewDesc(
+			"outline_collection_views_rate",
+			"Exponentially weighted moving average of views per hour of the documents of a collection",
+			[]string{"collection_id", "collection_name"}, nil),
+		aggregatedDocumentsCount: prometheus.NewDesc(
+			"outline_collection_aggregated_documents_count",
+			"Number of documents of a collection exported only in aggregate",
+			[]string{"collection_id", "collection_name"}, nil),
+		aggregatedDocumentViews: prometheus.NewDesc(
+			"outline_collection_aggregated_document_views",
+			"Total views of the documents of a collection exported only in aggregate",
+			[]string{"collection_id", "collection_name"}, nil),
+		aggregatedDocumentSize: prometheus.NewDesc(
+			"outline_collection_aggregated_document_size_bytes",
+			"Total text size of the documents of a collection exported only in aggregate",
+			[]string{"collection_id", "collection_name"}, nil),
+		aggregatedDocumentRevisions: prometheus.NewDesc(
+			"outline_collection_aggregated_document_revisions",
+			"Total revisions of the documents of a collection exported only in aggregate",
+			[]string{"collection_id", "collection_name"}, nil),
+		aggregatedDocumentAge: prometheus.NewDesc(
+			"outline_collection_aggregated_document_age_seconds",
+			"Average age of the documents of a collection exported only in aggregate",
+			[]string{"collection_id", "collection_name"}, nil),
+		aggregatedDocumentUpdateAge: prometheus.NewDesc(
+			"outline_collection_aggregated_document_update_age_seconds",
+			"Average time since the documents of a collection exported only in aggregate were last updated",
+			[]string{"collection_id", "collection_name"}, nil),
 	}
 }
 
 func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- e.up
 	ch <- e.scrapeSuccessTimestamp
+	ch <- e.scrapeGeneration
+	ch <- e.refreshDuration
+	ch <- e.stageDuration
+	ch <- e.pagesFetched
+	ch <- e.itemsFetched
+	ch <- e.cacheAge
+	ch <- e.circuitBreakerState
+	ch <- e.serverInfo
+	ch <- e.apiCompatible
+	ch <- e.collectorSupported
+	ch <- e.collectorEnabled
+	ch <- e.lastErrorTimestamp
+	ch <- e.cacheItems
+	ch <- e.cacheBytes
 	ch <- e.collectionsTotal
 	ch <- e.collectionDocumentsCount
 	ch <- e.collectionAge
+	ch <- e.collectionFreshestDocumentAge
+	ch <- e.collectionCreatedTimestamp
+	ch <- e.collectionLastDocumentUpdateTimestamp
 	ch <- e.documentsTotal
+	ch <- e.documentsStaleTotal
 	ch <- e.documentRevisions
 	ch <- e.documentViews
 	ch <- e.documentAge
 	ch <- e.documentSize
 	ch <- e.documentUpdateAge
+	ch <- e.documentWords
+	ch <- e.collectionWords
+	ch <- e.documentSizeHistogram
+	ch <- e.documentUpdateAgeHistogram
+	ch <- e.documentTasks
+	ch <- e.documentTasksCompleted
+	ch <- e.documentImages
+	ch <- e.documentAttachments
+	ch <- e.documentInternalLinks
+	ch <- e.documentBrokenLinks
+	ch <- e.internalLinksTotal
+	ch <- e.brokenLinksTotal
+	ch <- e.documentCreatedTimestamp
+	ch <- e.documentUpdatedTimestamp
+	ch <- e.documentLastRevisionAge
+	ch <- e.documentLastRevisionTimestamp
+	ch <- e.documentPublishedTimestamp
+	ch <- e.documentPublished
+	ch <- e.documentArchivedTimestamp
+	ch <- e.documentDeletedTimestamp
 	ch <- e.usersTotal
+	ch <- e.usersByRole
+	ch <- e.usersSuspended
+	ch <- e.usersInvited
+	ch <- e.oldestInviteAge
 	ch <- e.userLastActive
 	ch <- e.userAge
+	ch <- e.userLastActiveTimestamp
+	ch <- e.userCreatedTimestamp
+	ch <- e.usersLastActive
+	ch <- e.ownerDocumentsCount
+	ch <- e.documentsCreatedBy
+	ch <- e.ownerStaleDocumentsCount
+	ch <- e.documentTags
+	ch <- e.tagDocumentsCount
+	ch <- e.tagCollectionDocumentsCount
+	ch <- e.savedSearchResults
+	ch <- e.commentsTotal
+	ch <- e.commentMessages
+	ch <- e.documentComments
+	ch <- e.groupsTotal
+	ch <- e.groupMembers
+	ch <- e.groupAge
+	ch <- e.groupCreatedTimestamp
+	ch <- e.draftsTotal
+	ch <- e.documentsPublishedTotal
+	ch <- e.documentsUnpublishedTotal
+	ch <- e.userDrafts
+	ch <- e.archivedTotal
+	ch <- e.deletedTotal
+	ch <- e.templatesTotal
+	ch <- e.collectionTemplates
+	ch <- e.templateUpdateAge
+	ch <- e.templateUpdatedTimestamp
+	ch <- e.teamInfo
+	ch <- e.attachmentsTotal
+	ch <- e.collectionAttachments
+	ch <- e.attachmentsBytes
+	ch <- e.collectionAttachmentsBytes
+	ch <- e.sharesTotal
+	ch <- e.sharePublished
+	ch <- e.shareAge
+	ch <- e.shareCreatedTimestamp
+	ch <- e.starsTotal
+	ch <- e.documentStars
+	ch <- e.fileOperations
+	ch <- e.fileOperationOldestIncomplete
+	ch <- e.documentViewsRateDesc
+	ch <- e.collectionViewsRateDesc
+	ch <- e.collectionPrivate
+	ch <- e.collectionPermission
+	ch <- e.collectionSharing
+	ch <- e.collectionMembers
+	ch <- e.collectionGroups
+	ch <- e.collectionRootDocuments
+	ch <- e.collectionMaxDepth
+	ch <- e.collectionAvgDepth
+	ch <- e.aggregatedDocumentsCount
+	ch <- e.aggregatedDocumentViews
+	ch <- e.aggregatedDocumentSize
+	ch <- e.aggregatedDocumentRevisions
+	ch <- e.aggregatedDocumentAge
+	ch <- e.aggregatedDocumentUpdateAge
 	e.scrapeErrorsTotal.Describe(ch)
+	e.commentsCreated.Describe(ch)
 	e.scrapeDurationSeconds.Describe(ch)
+	e.collectionDocumentsAdded.Describe(ch)
+	e.collectionDocumentsRemoved.Describe(ch)
+	e.entitiesDeleted.Describe(ch)
+	e.clockSkewDetections.Describe(ch)
+	e.schemaWarnings.Describe(ch)
+	e.apiKeyRequests.Describe(ch)
+	e.apiConnections.Describe(ch)
+	e.apiKeyErrors.Describe(ch)
+	e.apiRequests.Describe(ch)
+	e.apiRequestFailures.Describe(ch)
+	e.apiRateLimit.Describe(ch)
+	e.apiRateLimitRemaining.Describe(ch)
+	e.apiRateLimitReset.Describe(ch)
+	e.eventsTotal.Describe(ch)
 }
 
-func (e *Exporter) debug(format string, args ...any) {
-	if e.config.Debug {
-		log.Printf("[DEBUG] "+format, args...)
-	}
-}
-
-func (e *Exporter) fetch(path string, target any, body any) error {
+func (e *Exporter) fetch(ctx context.Context, path string, target any, body any) error {
 	maxRetries := 3
 	baseDelay := time.Second
 
+	allowed, trial := e.breaker.allow()
+	if !allowed {
+		return errCircuitOpen
+	}
+	if trial {
+		defer e.breaker.release()
+	}
+
+	var delay time.Duration
 	for attempt := 0; attempt <= maxRetries; attempt++ {
 		if attempt > 0 {
-			delay := baseDelay * time.Duration(1<<uint(attempt-1))
-			log.Printf("Retry %d/%d after %v for %s", attempt, maxRetries, delay, path)
-			time.Sleep(delay)
+			e.logger.Info("Retrying Outline API request", "endpoint", path, "attempt", attempt, "max_retries", maxRetries, "delay", delay)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err := e.limiter.wait(ctx); err != nil {
+			return err
 		}
 
-		err := e.doFetch(path, target, body)
+		err := e.doFetch(ctx, path, target, body)
 		if err == nil {
+			e.breaker.record(nil)
 			return nil
 		}
 
-		if attempt < maxRetries && (strings.Contains(err.Error(), "EOF") || strings.Contains(err.Error(), "timeout")) {
-			e.debug("Retryable error: %v", err)
+		delay = baseDelay * time.Duration(1<<uint(attempt))
+		retryAfter, limited := rateLimited(err)
+		// With several keys the next attempt uses another one, which isn't
+		// subject to the wait Outline asked for.
+		if limited && retryAfter > 0 && !e.throttled(err) {
+			delay = retryAfter
+		}
+		if attempt < maxRetries && (strings.Contains(err.Error(), "EOF") || strings.Contains(err.Error(), "timeout") || limited) {
+			e.logger.Debug("Retryable error", "endpoint", path, "error", err)
 			continue
 		}
 
+		// Requests cancelled because Prometheus went away and optional
+		// endpoints missing on older versions aren't Outline errors.
+		if ctx.Err() != nil || expectedNotFound(ctx, err) {
+			return err
+		}
+		e.errors.record(path, err)
+		e.apiRequestFailures.WithLabelValues(endpointOf(path)).Inc()
+		e.breaker.record(err)
 		return err
 	}
 
 	return fmt.Errorf("max retries exceeded")
 }
 
-func (e *Exporter) doFetch(path string, target any, body any) error {
-	client := &http.Client{Timeout: e.config.ScrapeTimeout}
+func (e *Exporter) doFetch(ctx context.Context, path string, target any, body any) error {
+	key, label := e.keys.pick()
+	start := time.Now()
+	ctx, span := tracing.start(ctx, "POST "+endpointOf(path), spanKindClient,
+		"http.request.method", "POST", "url.full", e.config.OutlineAPIURL+path, "outline.api_key", label)
+	err := e.doFetchWithKey(ctx, path, key, label, target, body)
+	e.recordKeyUsage(label, err)
+	span.end(err)
+
+	attrs := []any{"endpoint", path, "key", label, "duration", time.Since(start)}
+	var apiErr *apiError
+	if err == nil {
+		attrs = append(attrs, "status", http.StatusOK)
+	} else if errors.As(err, &apiErr) {
+		attrs = append(attrs, "status", apiErr.StatusCode)
+	} else {
+		attrs = append(attrs, "error", err)
+	}
+	e.logger.Debug("Outline API request", attrs...)
+	return err
+}
+
+// endpointOf strips the query string pagination adds to a path, so metrics
+// are labelled by API endpoint only.
+func endpointOf(path string) string {
+	if u, err := url.Parse(path); err == nil {
+		return u.Path
+	}
+	return path
+}
+
+func (e *Exporter) doFetchWithKey(ctx context.Context, path, key, label string, target any, body any) error {
 	fullURL := e.config.OutlineAPIURL + path
-	e.debug("POST %s", fullURL)
 
 	var bodyReader io.Reader
+	var bodyBytes []byte
 	if body != nil {
-		bodyBytes, err := json.Marshal(body)
+		var err error
+		bodyBytes, err = json.Marshal(body)
 		if err != nil {
 			return fmt.Errorf("marshal body: %w", err)
 		}
 		bodyReader = bytes.NewBuffer(bodyBytes)
-		e.debug("Body: %s", string(bodyBytes))
 	}
 
-	req, err := http.NewRequest("POST", fullURL, bodyReader)
+	req, err := http.NewRequestWithContext(e.traceConnections(ctx), "POST", fullURL, bodyReader)
 	if err != nil {
 		return fmt.Errorf("new request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+e.config.OutlineAPIKey)
+	req.Header.Set("Authorization", "Bearer "+key)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
-
-	if e.config.Debug {
-		if dump, err := httputil.DumpRequestOut(req, true); err == nil {
-			e.debug("REQUEST:\n%s", string(dump))
-		}
+	if span := spanFrom(ctx); span != nil {
+		req.Header.Set("traceparent", span.traceparent())
+	}
+	// Responses may differ between keys, and the same request is decoded
+	// into different types, e.g. by the capability probes, so both are part
+	// of the cache key.
+	cacheKey := fmt.Sprintf("%s %T %s %s", key, target, path, bodyBytes)
+	if e.responses != nil {
+		e.responses.prepare(req, cacheKey)
 	}
 
-	resp, err := client.Do(req)
+	e.dumpRequest(req)
+
+	resp, err := e.client.Do(req)
 	if err != nil {
+		e.apiRequests.WithLabelValues(endpointOf(path), "error").Inc()
 		return fmt.Errorf("do request: %w", err)
 	}
 	defer resp.Body.Close()
+	e.apiRequests.WithLabelValues(endpointOf(path), strconv.Itoa(resp.StatusCode)).Inc()
+	spanFrom(ctx).set("http.response.status_code", resp.StatusCode)
+	e.recordRateLimit(label, resp.Header)
+
+	// Successful responses are decoded while they are read, unless they are
+	// dumped for debugging, so large listings aren't buffered as a whole.
+	if resp.StatusCode == http.StatusOK && !e.config.Debug {
+		if err := decodeResponse(resp.Body, target); err != nil {
+			return fmt.Errorf("decode body: %w", err)
+		}
+		// Reading up to EOF lets the connection be reused.
+		io.Copy(io.Discard, resp.Body)
+		if e.responses != nil {
+			e.responses.store(cacheKey, resp, target)
+		}
+		return nil
+	}
 
 	responseData, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return fmt.Errorf("read body: %w", err)
 	}
 
-	if e.config.Debug {
-		if dump, err := httputil.DumpResponse(resp, false); err == nil {
-			e.debug("RESPONSE:\n%s\n%s", string(dump), string(responseData))
-		}
-	}
+	e.dumpResponse(resp, responseData)
 
+	if resp.StatusCode == http.StatusNotModified && e.responses != nil && e.responses.restore(cacheKey, target) {
+		e.logger.Debug("Outline API response not modified", "endpoint", path)
+		return nil
+	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("status %d: %s", resp.StatusCode, string(responseData))
+		return &apiError{StatusCode: resp.StatusCode, Body: string(responseData), RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
 	}
 
-	return json.Unmarshal(responseData, target)
+	if err := decodeResponse(bytes.NewReader(responseData), target); err != nil {
+		return fmt.Errorf("decode body: %w", err)
+	}
+	if e.responses != nil {
+		e.responses.store(cacheKey, resp, target)
+	}
+	return nil
 }
 
 func (e *Exporter) shouldPaginate(pagination Pagination, itemCount int) bool {
@@ -269,22 +1276,33 @@ func (e *Exporter) shouldPaginate(pagination Pagination, itemCount int) bool {
 	exactLimit := itemCount == pagination.Limit
 	shouldContinue := hasNext && nonEmpty && exactLimit
 
-	e.debug("Paginate: next=%s trim=%v exact=%v (%d==%d) => %v",
-		pagination.NextPath, nonEmpty, exactLimit, itemCount, pagination.Limit, shouldContinue)
+	e.logger.Debug("Pagination", "next_path", pagination.NextPath, "non_empty", nonEmpty, "exact_limit", exactLimit,
+		"items", itemCount, "limit", pagination.Limit, "continue", shouldContinue)
 	return shouldContinue
 }
 
-func fetchAll[T any](exporter *Exporter, path string) ([]T, error) {
-	var allItems []T
-	exporter.debug("Fetch %s", path)
+// fetchAll walks every page of a list endpoint. params are sent with each
+// request so filters such as a search query survive pagination.
+func fetchAll[T any](ctx context.Context, exporter *Exporter, path string, params map[string]any) (allItems []T, err error) {
+	ctx, span := tracing.start(ctx, path, spanKindInternal, "outline.endpoint", path)
+	defer func() {
+		span.set("outline.items", len(allItems))
+		span.end(err)
+	}()
+	exporter.logger.Debug("Fetching list", "endpoint", path)
+
+	firstBody := map[string]any{"limit": exporter.config.PageLimit, "offset": 0}
+	for key, value := range params {
+		firstBody[key] = value
+	}
 
 	var firstResponse apiResp[T]
-	if err := exporter.fetch(path, &firstResponse, map[string]int{"limit": exporter.config.PageLimit, "offset": 0}); err != nil {
+	if err := fetchPage(ctx, exporter, path, &firstResponse, firstBody); err != nil {
 		return nil, fmt.Errorf("fetch first page: %w", err)
 	}
 
 	allItems = append(allItems, firstResponse.Data...)
-	log.Printf("Fetched %d items (page 1)", len(firstResponse.Data))
+	exporter.logger.Info("Fetched page", "endpoint", path, "page", 1, "items", len(firstResponse.Data))
 
 	if !exporter.shouldPaginate(firstResponse.Pagination, len(firstResponse.Data)) {
 		return allItems, nil
@@ -297,21 +1315,23 @@ func fetchAll[T any](exporter *Exporter, path string) ([]T, error) {
 
 	for nextPath != "" && strings.TrimSpace(nextPath) != "" {
 		if seenPaths[nextPath] {
-			exporter.debug("Already seen path %s, stopping pagination", nextPath)
+			exporter.logger.Debug("Already seen path, stopping pagination", "endpoint", path, "next_path", nextPath)
 			break
 		}
 		seenPaths[nextPath] = true
 
-		exporter.debug("Next: %s", nextPath)
-
 		var response apiResp[T]
-		if err := exporter.fetch(nextPath, &response, map[string]string{}); err != nil {
+		body := params
+		if body == nil {
+			body = map[string]any{}
+		}
+		if err := fetchPage(ctx, exporter, nextPath, &response, body); err != nil {
 			return allItems, fmt.Errorf("fetch page %d: %w", pageNumber+1, err)
 		}
 
 		allItems = append(allItems, response.Data...)
 		pageNumber++
-		log.Printf("Fetched %d items (page %d, total %d)", len(response.Data), pageNumber, len(allItems))
+		exporter.logger.Info("Fetched page", "endpoint", path, "page", pageNumber, "items", len(response.Data), "total", len(allItems))
 
 		if !exporter.shouldPaginate(response.Pagination, len(response.Data)) {
 			break
@@ -319,55 +1339,285 @@ func fetchAll[T any](exporter *Exporter, path string) ([]T, error) {
 		nextPath = response.Pagination.NextPath
 	}
 
-	log.Printf("Completed: %d items across %d pages", len(allItems), pageNumber)
+	exporter.logger.Info("Fetched list", "endpoint", path, "items", len(allItems), "pages", pageNumber)
 	return allItems, nil
 }
 
-func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
-	startTime := time.Now()
-	success := true
-
-	collections, err := fetchAll[Collection](e, "/api/collections.list")
-	if err != nil {
-		log.Printf("Error fetching collections: %v", err)
-		e.scrapeErrorsTotal.Inc()
-		success = false
+func (e *Exporter) scrape(ctx context.Context) *snapshot {
+	snap := &snapshot{
+		searchResults:  make(map[string]int),
+		failed:         make(map[string]bool),
+		skipped:        make(map[string]bool),
+		stageDurations: make(map[string]time.Duration),
+		pagesFetched:   make(map[string]int),
+		itemsFetched:   make(map[string]int),
+		takenAt:        time.Now(),
 	}
-
-	documents, err := fetchAll[Document](e, "/api/documents.list")
-	if err != nil {
-		log.Printf("Error fetching documents: %v", err)
-		e.scrapeErrorsTotal.Inc()
-		success = false
+	defer func() {
+		snap.refreshDuration = time.Since(snap.takenAt)
+	}()
+
+	e.mu.Lock()
+	e.generation++
+	snap.generation = e.generation
+	e.mu.Unlock()
+
+	if e.config.CollectionFilter.active() {
+		start := time.Now()
+		e.resolveCollections(ctx)
+		snap.stageDurations["collection_filter"] = time.Since(start)
 	}
 
-	users, err := fetchAll[User](e, "/api/users.list")
-	if err != nil {
-		log.Printf("Error fetching users: %v", err)
-		e.scrapeErrorsTotal.Inc()
-		success = false
+	// The resources are independent, so they are fetched concurrently and
+	// the scrape takes as long as the slowest endpoint. A failing resource
+	// doesn't cancel the others: partial data is still exported.
+	var g errgroup.Group
+	var mu sync.Mutex
+	resource := func(name string, fetch func(ctx context.Context) error) {
+		if !e.collectorActive(name) {
+			if e.collectorRejected(name) {
+				snap.resources = append(snap.resources, name)
+				snap.failed[name] = true
+				return
+			}
+			snap.skipped[name] = true
+			return
+		}
+		snap.resources = append(snap.resources, name)
+		g.Go(func() error {
+			start := time.Now()
+			counter := &pageCounter{}
+			ctx, span := tracing.start(ctx, name, spanKindInternal, "outline.resource", name)
+			err := fetch(withPageCounter(ctx, counter))
+			span.end(err)
+			e.recordResult(name, err)
+			if ctx.Err() == nil {
+				e.statuses.record(name, err)
+			}
+			mu.Lock()
+			snap.stageDurations[name] = time.Since(start)
+			if pages := counter.pages.Load(); pages > 0 {
+				snap.pagesFetched[name] = int(pages)
+				snap.itemsFetched[name] = int(counter.items.Load())
+			}
+			if err != nil {
+				snap.failed[name] = true
+			}
+			mu.Unlock()
+			if err != nil {
+				e.scrapeErrorsTotal.WithLabelValues(errorClass(err)).Inc()
+			}
+			return nil
+		})
 	}
 
-	if success {
-		ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 1)
-		ch <- prometheus.MustNewConstMetric(e.scrapeSuccessTimestamp, prometheus.GaugeValue, float64(time.Now().Unix()))
-	} else {
-		ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 0)
+	resource("team", func(ctx context.Context) (err error) {
+		snap.team, err = e.fetchAuthInfo(ctx)
+		if err != nil {
+			e.logger.Error("Error fetching team info", "error", err)
+		}
+		return err
+	})
+	resource("collections", func(ctx context.Context) (err error) {
+		snap.collections, err = fetchAll[Collection](ctx, e, "/api/collections.list", nil)
+		snap.collections = e.filterCollections(snap.collections)
+		if err != nil {
+			e.logger.Error("Error fetching collections", "error", err)
+		}
+		return err
+	})
+	resource("memberships", func(ctx context.Context) (err error) {
+		snap.memberships, err = e.fetchMemberships(ctx)
+		if err != nil {
+			e.logger.Error("Error fetching collection memberships", "error", err)
+		}
+		return err
+	})
+	resource("documents", func(ctx context.Context) (err error) {
+		snap.documents, err = e.fetchDocuments(ctx)
+		if err != nil {
+			e.logger.Error("Error fetching documents", "error", err)
+			return err
+		}
+		if e.config.AttachmentSizes {
+			e.sizeAttachments(ctx, snap.documents)
+		}
+		if e.config.RevisionHistory {
+			e.fetchRevisions(ctx, snap.documents)
+		}
+		return nil
+	})
+	resource("drafts", func(ctx context.Context) (err error) {
+		snap.drafts, err = e.fetchDrafts(ctx)
+		if err != nil {
+			e.logger.Error("Error fetching drafts", "error", err)
+		}
+		return err
+	})
+	resource("archived", func(ctx context.Context) (err error) {
+		snap.archived, err = fetchAll[Document](ctx, e, "/api/documents.archived", nil)
+		snap.archived = e.filterDocuments(snap.archived)
+		if err != nil {
+			e.logger.Error("Error fetching archived documents", "error", err)
+		}
+		return err
+	})
+	resource("deleted", func(ctx context.Context) (err error) {
+		snap.deleted, err = fetchAll[Document](ctx, e, "/api/documents.deleted", nil)
+		snap.deleted = e.filterDocuments(snap.deleted)
+		if err != nil {
+			e.logger.Error("Error fetching deleted documents", "error", err)
+		}
+		return err
+	})
+	resource("templates", func(ctx context.Context) (err error) {
+		snap.templates, err = e.fetchTemplates(ctx)
+		if err != nil {
+			e.logger.Error("Error fetching templates", "error", err)
+		}
+		return err
+	})
+	resource("users", func(ctx context.Context) (err error) {
+		snap.users, err = fetchAll[User](ctx, e, "/api/users.list", nil)
+		if err != nil {
+			e.logger.Error("Error fetching users", "error", err)
+		}
+		return err
+	})
+	resource("user_states", func(ctx context.Context) (err error) {
+		snap.userStates, err = e.fetchUserStates(ctx)
+		if err != nil {
+			e.logger.Error("Error fetching suspended and invited users", "error", err)
+		}
+		return err
+	})
+	resource("comments", func(ctx context.Context) (err error) {
+		snap.comments, err = fetchAll[Comment](ctx, e, "/api/comments.list", nil)
+		if err != nil {
+			e.logger.Error("Error fetching comments", "error", err)
+		}
+		return err
+	})
+	resource("groups", func(ctx context.Context) (err error) {
+		snap.groups, err = fetchAll[Group](ctx, e, "/api/groups.list", nil)
+		if err != nil {
+			e.logger.Error("Error fetching groups", "error", err)
+		}
+		return err
+	})
+	resource("shares", func(ctx context.Context) (err error) {
+		snap.shares, err = fetchAll[Share](ctx, e, "/api/shares.list", nil)
+		if err != nil {
+			e.logger.Error("Error fetching shares", "error", err)
+		}
+		return err
+	})
+	resource("stars", func(ctx context.Context) (err error) {
+		snap.stars, err = fetchAll[Star](ctx, e, "/api/stars.list", nil)
+		if err != nil {
+			e.logger.Error("Error fetching stars", "error", err)
+		}
+		return err
+	})
+	resource("file_operations", func(ctx context.Context) (err error) {
+		snap.fileOperations, err = e.fetchFileOperations(ctx)
+		if err != nil {
+			e.logger.Error("Error fetching file operations", "error", err)
+		}
+		return err
+	})
+	resource("events", func(ctx context.Context) error {
+		err := e.fetchEvents(ctx)
+		if err != nil {
+			e.logger.Error("Error fetching events", "error", err)
+		}
+		return err
+	})
+	resource("searches", func(ctx context.Context) error {
+		var failed error
+		for _, search := range e.config.SavedSearches {
+			results, err := fetchAll[SearchResult](ctx, e, "/api/documents.search", map[string]any{"query": search.Query})
+			if err != nil {
+				e.logger.Error("Error running saved search", "search", search.Name, "error", err)
+				failed = err
+				continue
+			}
+			snap.searchResults[search.Name] = len(results)
+		}
+		return failed
+	})
+	g.Wait()
+	e.reconcileInventory(snap)
+
+	return snap
+}
+
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	e.collect(e.ctx, ch)
+}
+
+// collect builds the metrics. Without SCRAPE_INTERVAL it fetches the data
+// first, cancelled with ctx.
+func (e *Exporter) collect(ctx context.Context, ch chan<- prometheus.Metric) {
+	startTime := time.Now()
+	ctx, span := tracing.start(ctx, "collect", spanKindInternal, "outline.instance", e.config.InstanceName)
+	defer span.end(nil)
+	snap := e.current(ctx)
+	e.collectCacheSize(ch)
+	e.collectCapabilities(ch)
+	e.collectCompatibility(ch)
+	if last := e.errors.last(); !last.IsZero() {
+		ch <- prometheus.MustNewConstMetric(e.lastErrorTimestamp, prometheus.GaugeValue, float64(last.Unix()))
+	}
+	ch <- prometheus.MustNewConstMetric(e.scrapeGeneration, prometheus.GaugeValue, float64(snap.generation))
+	ch <- prometheus.MustNewConstMetric(e.refreshDuration, prometheus.GaugeValue, snap.refreshDuration.Seconds())
+	for stage, duration := range snap.stageDurations {
+		ch <- prometheus.MustNewConstMetric(e.stageDuration, prometheus.GaugeValue, duration.Seconds(), stage)
+	}
+	for resource, pages := range snap.pagesFetched {
+		ch <- prometheus.MustNewConstMetric(e.pagesFetched, prometheus.GaugeValue, float64(pages), resource)
+		ch <- prometheus.MustNewConstMetric(e.itemsFetched, prometheus.GaugeValue, float64(snap.itemsFetched[resource]), resource)
+	}
+	ch <- prometheus.MustNewConstMetric(e.cacheAge, prometheus.GaugeValue, time.Since(snap.takenAt).Seconds())
+	e.collectCircuitBreaker(ch)
+	collections, documents, users := snap.collections, snap.documents, snap.users
+
+	// The aggregate has an empty resource label, which Prometheus stores as
+	// no label at all, so it keeps the series outline_up always had.
+	ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, boolValue(snap.ok()), "")
+	if snap.ok() {
+		ch <- prometheus.MustNewConstMetric(e.scrapeSuccessTimestamp, prometheus.GaugeValue, float64(snap.takenAt.Unix()))
+	}
+	for _, resource := range snap.resources {
+		ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, boolValue(!snap.failed[resource]), resource)
 	}
 
 	if len(collections) > 0 {
 		ch <- prometheus.MustNewConstMetric(e.collectionsTotal, prometheus.GaugeValue, float64(len(collections)))
 
 		documentCounts := make(map[string]int)
+		lastUpdates := make(map[string]time.Time)
 		for _, document := range documents {
 			documentCounts[document.CollectionId]++
+			if document.UpdatedAt.After(lastUpdates[document.CollectionId]) {
+				lastUpdates[document.CollectionId] = document.UpdatedAt
+			}
 		}
 
 		for _, collection := range collections {
 			ch <- prometheus.MustNewConstMetric(e.collectionDocumentsCount, prometheus.GaugeValue,
 				float64(documentCounts[collection.ID]), collection.ID, collection.Name)
-			ch <- prometheus.MustNewConstMetric(e.collectionAge, prometheus.GaugeValue,
-				time.Since(collection.CreatedAt).Seconds(), collection.ID, collection.Name)
+			e.collectAge(ch, e.collectionAge, e.collectionCreatedTimestamp,
+				collection.CreatedAt, collection.ID, collection.Name)
+			if lastUpdate, ok := lastUpdates[collection.ID]; ok {
+				e.collectAge(ch, e.collectionFreshestDocumentAge, e.collectionLastDocumentUpdateTimestamp,
+					lastUpdate, collection.ID, collection.Name)
+			}
+			if rate, ok := e.collectionViewsRateFor(collection.ID); ok {
+				ch <- prometheus.MustNewConstMetric(e.collectionViewsRateDesc, prometheus.GaugeValue,
+					rate, collection.ID, collection.Name)
+			}
+			e.collectCollectionAccess(ch, collection)
 		}
 	}
 
@@ -380,121 +1630,836 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 			}
 		}
 
-		e.debug("Documents: total=%d unique=%d", len(documents), len(uniqueDocuments))
+		e.logger.Debug("Documents", "total", len(documents), "unique", len(uniqueDocuments))
 		if len(documents) != len(uniqueDocuments) {
-			log.Printf("Warning: %d duplicate documents", len(documents)-len(uniqueDocuments))
+			e.logger.Warn("Duplicate documents", "count", len(documents)-len(uniqueDocuments))
 		}
 
 		ch <- prometheus.MustNewConstMetric(e.documentsTotal, prometheus.GaugeValue, float64(len(uniqueDocuments)))
 
-		for _, document := range uniqueDocuments {
-			ch <- prometheus.MustNewConstMetric(e.documentRevisions, prometheus.GaugeValue,
-				float64(document.Revision), document.ID, document.CollectionId)
+		for _, threshold := range e.config.StaleThresholds {
+			cutoff := e.cutoff(threshold)
+			stale := 0
+			for _, document := range uniqueDocuments {
+				if document.UpdatedAt.Before(cutoff) {
+					stale++
+				}
+			}
+			ch <- prometheus.MustNewConstMetric(e.documentsStaleTotal, prometheus.GaugeValue, float64(stale), formatDuration(threshold))
+		}
+
+		staleCutoff := e.cutoff(e.config.StaleAfter)
+		ownerCounts := make(map[string]int)
+		ownerStaleCounts := make(map[string]int)
+		documentTags := make(map[string][]string)
+		tagCounts := make(map[string]int)
+		tagCollectionCounts := make(map[[2]string]int)
+		for key, document := range uniqueDocuments {
+			tags := e.content.tags(document.Text)
+			documentTags[key] = tags
+			for _, tag := range tags {
+				tagCounts[tag]++
+				tagCollectionCounts[[2]string{tag, document.CollectionId}]++
+			}
+
+			owner := e.content.owner(document.Text)
+			if owner == "" {
+				continue
+			}
+			ownerCounts[owner]++
+			if document.UpdatedAt.Before(staleCutoff) {
+				ownerStaleCounts[owner]++
+			}
+		}
+
+		for owner, count := range ownerCounts {
+			ch <- prometheus.MustNewConstMetric(e.ownerDocumentsCount, prometheus.GaugeValue, float64(count), owner)
+			ch <- prometheus.MustNewConstMetric(e.ownerStaleDocumentsCount, prometheus.GaugeValue, float64(ownerStaleCounts[owner]), owner)
+		}
+
+		if e.config.PerUserMetrics {
+			e.collectCreators(ch, uniqueDocuments)
+		}
+
+		for tag, count := range tagCounts {
+			ch <- prometheus.MustNewConstMetric(e.tagDocumentsCount, prometheus.GaugeValue, float64(count), tag)
+		}
+		for key, count := range tagCollectionCounts {
+			ch <- prometheus.MustNewConstMetric(e.tagCollectionDocumentsCount, prometheus.GaugeValue, float64(count), key[0], key[1])
+		}
+
+		detailed := e.detailedDocuments(uniqueDocuments)
+		commentCounts := commentThreads(snap.comments)
+		collectionNames := snap.collectionNames()
+		aggregatedCounts := make(map[string]int)
+		aggregatedViews := make(map[string]int)
+		aggregatedSizes := make(map[string]int)
+		aggregatedRevisions := make(map[string]int)
+		aggregatedAges := make(map[string]float64)
+		aggregatedUpdateAges := make(map[string]float64)
+		collectionWords := make(map[string]int)
+		sizes := newCollectionHistograms(e.config.DocumentSizeBuckets)
+		updateAges := newCollectionHistograms(secondsBuckets(e.config.DocumentAgeBuckets))
+		knownDocuments := snap.documentURLIDs()
+		var totalLinks, totalBroken int
+		for key, document := range uniqueDocuments {
+			words := wordCount(document.Text)
+			sizes.observe(document.CollectionId, float64(document.size()))
+			updateAges.observe(document.CollectionId, e.age(document.UpdatedAt))
+			collectionWords[document.CollectionId] += words
+			links, broken := countLinks(document.Text, knownDocuments)
+			totalLinks += links
+			totalBroken += broken
+			if !detailed[key] {
+				aggregatedCounts[document.CollectionId]++
+				aggregatedViews[document.CollectionId] += document.Views
+				aggregatedSizes[document.CollectionId] += document.size()
+				aggregatedRevisions[document.CollectionId] += e.revisionCount(document)
+				aggregatedAges[document.CollectionId] += e.age(document.CreatedAt)
+				aggregatedUpdateAges[document.CollectionId] += e.age(document.UpdatedAt)
+				continue
+			}
+
+			e.collectRevisions(ch, document, collectionNames)
 			ch <- prometheus.MustNewConstMetric(e.documentViews, prometheus.GaugeValue,
-				float64(document.Views), document.ID, document.CollectionId)
-			ch <- prometheus.MustNewConstMetric(e.documentAge, prometheus.GaugeValue,
-				time.Since(document.CreatedAt).Seconds(), document.ID, document.CollectionId)
+				float64(document.Views), e.documentLabelValues(document, collectionNames)...)
+			if rate, ok := e.documentViewsRateFor(document.ID); ok {
+				ch <- prometheus.MustNewConstMetric(e.documentViewsRateDesc, prometheus.GaugeValue,
+					rate, e.documentLabelValues(document, collectionNames)...)
+			}
+			e.collectAge(ch, e.documentAge, e.documentCreatedTimestamp,
+				document.CreatedAt, e.documentLabelValues(document, collectionNames)...)
 			ch <- prometheus.MustNewConstMetric(e.documentSize, prometheus.GaugeValue,
-				float64(len(document.Text)), document.ID, document.CollectionId)
-			ch <- prometheus.MustNewConstMetric(e.documentUpdateAge, prometheus.GaugeValue,
-				time.Since(document.UpdatedAt).Seconds(), document.ID, document.CollectionId)
+				float64(document.size()), e.documentLabelValues(document, collectionNames)...)
+			if total, completed := tasks(document); total > 0 {
+				ch <- prometheus.MustNewConstMetric(e.documentTasks, prometheus.GaugeValue,
+					float64(total), e.documentLabelValues(document, collectionNames)...)
+				ch <- prometheus.MustNewConstMetric(e.documentTasksCompleted, prometheus.GaugeValue,
+					float64(completed), e.documentLabelValues(document, collectionNames)...)
+			}
+			if !e.config.ExcludeDocumentText {
+				ch <- prometheus.MustNewConstMetric(e.documentWords, prometheus.GaugeValue,
+					float64(words), e.documentLabelValues(document, collectionNames)...)
+				ch <- prometheus.MustNewConstMetric(e.documentImages, prometheus.GaugeValue,
+					float64(len(markdownImage.FindAllStringIndex(document.Text, -1))), e.documentLabelValues(document, collectionNames)...)
+				ch <- prometheus.MustNewConstMetric(e.documentAttachments, prometheus.GaugeValue,
+					float64(len(attachmentIDs(document.Text))), e.documentLabelValues(document, collectionNames)...)
+				ch <- prometheus.MustNewConstMetric(e.documentInternalLinks, prometheus.GaugeValue,
+					float64(links), e.documentLabelValues(document, collectionNames)...)
+				ch <- prometheus.MustNewConstMetric(e.documentBrokenLinks, prometheus.GaugeValue,
+					float64(broken), e.documentLabelValues(document, collectionNames)...)
+			}
+			e.collectAge(ch, e.documentUpdateAge, e.documentUpdatedTimestamp,
+				document.UpdatedAt, e.documentLabelValues(document, collectionNames)...)
+			for state, count := range commentCounts[document.ID] {
+				ch <- prometheus.MustNewConstMetric(e.documentComments, prometheus.GaugeValue,
+					float64(count), e.documentLabelValues(document, collectionNames, state)...)
+			}
+			collectTimestamp(ch, e.documentPublishedTimestamp, document.PublishedAt, e.documentLabelValues(document, collectionNames)...)
+			ch <- prometheus.MustNewConstMetric(e.documentPublished, prometheus.GaugeValue,
+				boolValue(!document.PublishedAt.IsZero()), e.documentLabelValues(document, collectionNames)...)
+			collectTimestamp(ch, e.documentArchivedTimestamp, document.ArchivedAt, e.documentLabelValues(document, collectionNames)...)
+			collectTimestamp(ch, e.documentDeletedTimestamp, document.DeletedAt, e.documentLabelValues(document, collectionNames)...)
+			for _, tag := range documentTags[key] {
+				ch <- prometheus.MustNewConstMetric(e.documentTags, prometheus.GaugeValue, 1, document.ID, tag)
+			}
 		}
+
+		for collectionID, count := range aggregatedCounts {
+			name := collectionNames[collectionID]
+			ch <- prometheus.MustNewConstMetric(e.aggregatedDocumentsCount, prometheus.GaugeValue,
+				float64(count), collectionID, name)
+			ch <- prometheus.MustNewConstMetric(e.aggregatedDocumentViews, prometheus.GaugeValue,
+				float64(aggregatedViews[collectionID]), collectionID, name)
+			ch <- prometheus.MustNewConstMetric(e.aggregatedDocumentSize, prometheus.GaugeValue,
+				float64(aggregatedSizes[collectionID]), collectionID, name)
+			ch <- prometheus.MustNewConstMetric(e.aggregatedDocumentRevisions, prometheus.GaugeValue,
+				float64(aggregatedRevisions[collectionID]), collectionID, name)
+			ch <- prometheus.MustNewConstMetric(e.aggregatedDocumentAge, prometheus.GaugeValue,
+				aggregatedAges[collectionID]/float64(count), collectionID, name)
+			ch <- prometheus.MustNewConstMetric(e.aggregatedDocumentUpdateAge, prometheus.GaugeValue,
+				aggregatedUpdateAges[collectionID]/float64(count), collectionID, name)
+		}
+		sizes.collect(ch, e.documentSizeHistogram, collectionNames)
+		updateAges.collect(ch, e.documentUpdateAgeHistogram, collectionNames)
+		if !e.config.ExcludeDocumentText {
+			ch <- prometheus.MustNewConstMetric(e.internalLinksTotal, prometheus.GaugeValue, float64(totalLinks))
+			ch <- prometheus.MustNewConstMetric(e.brokenLinksTotal, prometheus.GaugeValue, float64(totalBroken))
+			for collectionID, words := range collectionWords {
+				ch <- prometheus.MustNewConstMetric(e.collectionWords, prometheus.GaugeValue,
+					float64(words), collectionID, collectionNames[collectionID])
+			}
+		}
+		e.collectNesting(ch, documents, collectionNames)
 	}
 
 	if len(users) > 0 {
 		ch <- prometheus.MustNewConstMetric(e.usersTotal, prometheus.GaugeValue, float64(len(users)))
+		e.collectUserActivityHistogram(ch, users)
+		e.collectUserRoles(ch, users)
+	}
 
+	if len(users) > 0 && e.config.PerUserMetrics {
 		for _, user := range users {
-			ch <- prometheus.MustNewConstMetric(e.userLastActive, prometheus.GaugeValue,
-				time.Since(user.LastActiveAt).Seconds(), user.ID, user.Name)
-			ch <- prometheus.MustNewConstMetric(e.userAge, prometheus.GaugeValue,
-				time.Since(user.CreatedAt).Seconds(), user.ID, user.Name)
+			if !user.LastActiveAt.IsZero() {
+				e.collectAge(ch, e.userLastActive, e.userLastActiveTimestamp,
+					user.LastActiveAt, user.ID, user.Name)
+			}
+			e.collectAge(ch, e.userAge, e.userCreatedTimestamp,
+				user.CreatedAt, user.ID, user.Name)
 		}
 	}
 
+	if snap.fetched("user_states") {
+		e.collectUserStates(ch, snap.userStates)
+	}
+
+	if snap.fetched("comments") {
+		threads := commentThreads(snap.comments)
+		totals := make(map[string]int)
+		for _, states := range threads {
+			for state, count := range states {
+				totals[state] += count
+			}
+		}
+		for _, state := range commentStates {
+			ch <- prometheus.MustNewConstMetric(e.commentsTotal, prometheus.GaugeValue, float64(totals[state]), state)
+		}
+		ch <- prometheus.MustNewConstMetric(e.commentMessages, prometheus.GaugeValue, float64(len(snap.comments)))
+	}
+
+	if snap.fetched("groups") {
+		e.collectGroups(ch, snap.groups)
+	}
+
+	if snap.fetched("team") {
+		e.collectTeam(ch, snap.team)
+	}
+
+	if snap.fetched("memberships") {
+		e.collectMemberships(ch, snap.memberships)
+	}
+
+	if snap.fetched("documents") && !e.config.ExcludeDocumentText {
+		e.collectAttachments(ch, snap)
+	}
+
+	if snap.fetched("drafts") {
+		e.collectDrafts(ch, snap)
+	}
+
+	if snap.fetched("documents") {
+		e.collectPublication(ch, snap)
+	}
+
+	if snap.fetched("templates") {
+		e.collectTemplates(ch, snap)
+	}
+
+	if snap.fetched("archived") {
+		ch <- prometheus.MustNewConstMetric(e.archivedTotal, prometheus.GaugeValue, float64(len(snap.archived)))
+	}
+
+	if snap.fetched("deleted") {
+		ch <- prometheus.MustNewConstMetric(e.deletedTotal, prometheus.GaugeValue, float64(len(snap.deleted)))
+	}
+
+	if snap.fetched("shares") {
+		e.collectShares(ch, snap.shares)
+	}
+
+	if snap.fetched("stars") {
+		e.collectStars(ch, snap)
+	}
+
+	if snap.fetched("file_operations") {
+		e.collectFileOperations(ch, snap.fileOperations)
+	}
+
+	for name, count := range snap.searchResults {
+		ch <- prometheus.MustNewConstMetric(e.savedSearchResults, prometheus.GaugeValue, float64(count), name)
+	}
+
 	e.scrapeDurationSeconds.Set(time.Since(startTime).Seconds())
 	e.scrapeDurationSeconds.Collect(ch)
 	e.scrapeErrorsTotal.Collect(ch)
+	e.commentsCreated.Collect(ch)
+	e.collectionDocumentsAdded.Collect(ch)
+	e.collectionDocumentsRemoved.Collect(ch)
+	e.entitiesDeleted.Collect(ch)
+	e.clockSkewDetections.Collect(ch)
+	e.schemaWarnings.Collect(ch)
+	e.apiKeyRequests.Collect(ch)
+	e.apiConnections.Collect(ch)
+	e.apiKeyErrors.Collect(ch)
+	e.apiRequests.Collect(ch)
+	e.apiRequestFailures.Collect(ch)
+	e.apiRateLimit.Collect(ch)
+	e.apiRateLimitRemaining.Collect(ch)
+	e.apiRateLimitReset.Collect(ch)
+	e.eventsTotal.Collect(ch)
 }
 
-func main() {
+// configErrors collects the invalid settings found while loading the
+// configuration, so a reload can reject them instead of exiting.
+var configErrors []error
+
+func configError(format string, args ...any) {
+	configErrors = append(configErrors, fmt.Errorf(format, args...))
+}
+
+// loadConfig reads the configuration from the environment, applying the
+// defaults of the selected scrape profile.
+func loadConfig() (Config, error) {
+	configErrors = nil
+	profile := getChoice("SCRAPE_PROFILE", "standard", "light", "standard", "deep")
+	profileDefaults = scrapeProfiles[profile]
+
 	config := Config{
-		OutlineAPIURL: getEnv("OUTLINE_API_URL", "http://localhost:3000"),
-		OutlineAPIKey: getEnv("OUTLINE_API_KEY", ""),
-		ListenAddress: getEnv("LISTEN_ADDRESS", ":9877"),
-		MetricsPath:   getEnv("METRICS_PATH", "/metrics"),
-		ScrapeTimeout: getDuration("SCRAPE_TIMEOUT", 30*time.Second),
-		PageLimit:     getInt("PAGE_LIMIT", 100),
-		Debug:         getBool("DEBUG", false),
+		Profile:                 profile,
+		OutlineAPIURL:           getEnv("OUTLINE_API_URL", "http://localhost:3000"),
+		OutlineAPIKeys:          getList("OUTLINE_API_KEY"),
+		Instances:               getInstances("OUTLINE_INSTANCES"),
+		OutlineTLS:              getOutlineTLS(),
+		HTTPMaxIdleConns:        getInt("HTTP_MAX_IDLE_CONNS", 100),
+		HTTPMaxIdleConnsPerHost: getInt("HTTP_MAX_IDLE_CONNS_PER_HOST", 16),
+		HTTPIdleConnTimeout:     getDuration("HTTP_IDLE_CONN_TIMEOUT", 90*time.Second),
+		HTTPKeepAlives:          getBool("HTTP_KEEPALIVES", true),
+		HTTPCompression:         getBool("HTTP_COMPRESSION", true),
+		ListenAddress:           getEnv("LISTEN_ADDRESS", ":9877"),
+		ListenTLSCert:           getEnv("LISTEN_TLS_CERT", ""),
+		ListenTLSKey:            getEnv("LISTEN_TLS_KEY", ""),
+		BasicAuthUsers:          getBasicAuthUsers("BASIC_AUTH_USERS"),
+		MetricsPath:             getEnv("METRICS_PATH", "/metrics"),
+		OpenMetrics:             getBool("OPENMETRICS", true),
+		OpenMetricsCreated:      getBool("OPENMETRICS_CREATED_SAMPLES", false),
+		ScrapeTimeout:           getDuration("SCRAPE_TIMEOUT", 30*time.Second),
+		ScrapeInterval:          getDuration("SCRAPE_INTERVAL", 0),
+		CacheTTL:                getDuration("CACHE_TTL", 0),
+		CacheStaleTTL:           getDuration("CACHE_STALE_WHILE_REVALIDATE", 0),
+		APIRateLimit:            getFloat("API_RATE_LIMIT", 0),
+		APIRateBurst:            getInt("API_RATE_BURST", 0),
+		CircuitBreakerFailures:  getInt("CIRCUIT_BREAKER_FAILURES", 0),
+		CircuitBreakerCooldown:  getDuration("CIRCUIT_BREAKER_COOLDOWN", time.Minute),
+		ConditionalRequests:     getBool("CONDITIONAL_REQUESTS", false),
+		ConditionalRequestsAge:  getDuration("CONDITIONAL_REQUESTS_MAX_AGE", time.Hour),
+		PageLimit:               getInt("PAGE_LIMIT", 100),
+		Debug:                   getBool("DEBUG", false),
+		DebugUnsafe:             getBool("DEBUG_UNSAFE", false),
+		LogLevel:                getChoice("LOG_LEVEL", "info", "debug", "info", "warn", "error"),
+		LogFormat:               getChoice("LOG_FORMAT", "text", "text", "json"),
+		OwnerField:              getEnv("OWNER_FIELD", "owner"),
+		StaleAfter:              getDuration("STALE_AFTER", 90*24*time.Hour),
+		StaleThresholds:         getDurations("STALE_THRESHOLDS", []time.Duration{30 * day, 90 * day, 365 * day}),
+		Location:                getLocation("TIMEZONE", time.UTC),
+		CapabilityCheckInterval: getDuration("CAPABILITY_CHECK_INTERVAL", time.Hour),
+		CompatibilityCheck:      getChoice("COMPATIBILITY_CHECK", "warn", "warn", "strict", "off"),
+		AgeMetrics:              getChoice("AGE_METRICS", "age", "age", "timestamp", "both"),
+		AdminToken:              getEnv("ADMIN_TOKEN", ""),
+		EnablePprof:             getBool("ENABLE_PPROF", false),
+		PprofAddress:            getEnv("PPROF_LISTEN_ADDRESS", "localhost:6060"),
+		ErrorHistorySize:        getInt("ERROR_HISTORY_SIZE", 20),
+
+		DocumentActivityWindow:     getDuration("DOCUMENT_ACTIVITY_WINDOW", 0),
+		IncrementalScrape:          getBool("INCREMENTAL_SCRAPE", false),
+		FullScrapeInterval:         getDuration("FULL_SCRAPE_INTERVAL", 24*time.Hour),
+		ViewsRateAlpha:             getFloat("VIEWS_RATE_ALPHA", 0.3),
+		PerUserMetrics:             getBool("PER_USER_METRICS", true),
+		PerDocumentMetrics:         getBool("PER_DOCUMENT_METRICS", true),
+		DocumentTitleLabels:        getBool("DOCUMENT_TITLE_LABELS", false),
+		ExcludeDocumentText:        getBool("EXCLUDE_DOCUMENT_TEXT", false),
+		AttachmentSizes:            getBool("ATTACHMENT_SIZES", false),
+		RevisionHistory:            getBool("REVISION_HISTORY", false),
+		RevisionConcurrency:        getInt("REVISION_CONCURRENCY", 4),
+		RevisionDocumentsPerScrape: getInt("REVISION_DOCUMENTS_PER_SCRAPE", 100),
+		DocumentTopN:               getInt("DOCUMENT_TOP_N", 0),
+		DocumentTopNBy:             getList("DOCUMENT_TOP_N_BY"),
+		DocumentSizeBuckets:        getBuckets("DOCUMENT_SIZE_BUCKETS", []float64{1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20}),
+		DocumentAgeBuckets:         getDurations("DOCUMENT_AGE_BUCKETS", []time.Duration{day, 7 * day, 30 * day, 90 * day, 180 * day, 365 * day}),
+		UserActivityBuckets:        getDurations("USER_ACTIVITY_BUCKETS", []time.Duration{day, 7 * day, 30 * day, 90 * day, 365 * day}),
+		TagPattern:                 getEnv("TAG_PATTERN", ""),
+		SavedSearches:              getSavedSearches("SAVED_SEARCHES"),
+
+		CollectionsInclude: getList("COLLECTIONS_INCLUDE"),
+		CollectionsExclude: getList("COLLECTIONS_EXCLUDE"),
+		ProbeTargets:       getProbeTargets("PROBE_TARGETS"),
+
+		OutlineMetricsURL:    getEnv("OUTLINE_METRICS_URL", ""),
+		OutlineMetricsPrefix: getEnv("OUTLINE_METRICS_PREFIX", "outline_server_"),
+
+		ServeMetrics:    getBool("SERVE_METRICS", true),
+		PushInterval:    getDuration("PUSH_INTERVAL", time.Minute),
+		OTLPEndpoint:    getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		OTLPHeaders:     getKeyValues("OTEL_EXPORTER_OTLP_HEADERS"),
+		OTLPServiceName: getEnv("OTEL_SERVICE_NAME", "outline_exporter"),
+
+		PushOnce:            getBool("PUSH_ONCE", false),
+		PushgatewayURL:      getEnv("PUSHGATEWAY_URL", ""),
+		PushgatewayJob:      getEnv("PUSHGATEWAY_JOB", "outline_exporter"),
+		PushgatewayGrouping: getKeyValues("PUSHGATEWAY_GROUPING"),
+
+		RemoteWriteURL:         getEnv("REMOTE_WRITE_URL", ""),
+		RemoteWriteUsername:    getEnv("REMOTE_WRITE_USERNAME", ""),
+		RemoteWritePassword:    getEnv("REMOTE_WRITE_PASSWORD", ""),
+		RemoteWriteBearerToken: getEnv("REMOTE_WRITE_BEARER_TOKEN", ""),
+		RemoteWriteLabels:      getKeyValues("REMOTE_WRITE_LABELS"),
+
+		Collectors: make(map[string]bool),
+	}
+	for _, collector := range collectorEndpoints {
+		config.Collectors[collector.name] = getBool("COLLECTOR_"+strings.ToUpper(collector.name), true)
+		if enabled, ok := collectorFlags[collector.name]; ok {
+			config.Collectors[collector.name] = enabled
+		}
+	}
+	if config.Debug {
+		config.LogLevel = "debug"
 	}
 
-	if config.OutlineAPIKey == "" {
-		log.Fatal("OUTLINE_API_KEY environment variable is required")
+	if len(config.OutlineAPIKeys) == 0 && len(config.Instances) == 0 {
+		configError("OUTLINE_API_KEY environment variable is required")
+	}
+	if config.ViewsRateAlpha <= 0 || config.ViewsRateAlpha > 1 {
+		configError("VIEWS_RATE_ALPHA must be in (0, 1], got %v", config.ViewsRateAlpha)
+	}
+	if filter, err := newCollectionFilter(config.CollectionsInclude, config.CollectionsExclude); err != nil {
+		configError("%v", err)
+	} else {
+		config.CollectionFilter = filter
+	}
+	if len(config.DocumentTopNBy) == 0 {
+		config.DocumentTopNBy = []string{"views"}
+	}
+	for _, ranking := range config.DocumentTopNBy {
+		if topDocumentRankings[ranking] == nil {
+			configError("invalid DOCUMENT_TOP_N_BY ranking %q, expected views, size or updated", ranking)
+		}
+	}
+	if config.RevisionConcurrency < 1 {
+		configError("REVISION_CONCURRENCY must be at least 1, got %d", config.RevisionConcurrency)
+	}
+	if _, err := regexp.Compile(config.TagPattern); err != nil {
+		configError("invalid TAG_PATTERN: %v", err)
+	}
+	if (config.ListenTLSCert == "") != (config.ListenTLSKey == "") {
+		configError("LISTEN_TLS_CERT and LISTEN_TLS_KEY must be set together")
+	}
+	// Traces go to the signal specific endpoint as is, or to the shared one
+	// with the path of the traces appended, as in the OpenTelemetry SDKs.
+	if getChoice("OTEL_TRACES_EXPORTER", "none", "none", "otlp") == "otlp" {
+		config.TracesEndpoint = getEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+		if config.TracesEndpoint == "" && config.OTLPEndpoint != "" {
+			config.TracesEndpoint = strings.TrimSuffix(config.OTLPEndpoint, "/") + "/v1/traces"
+		}
+		if config.TracesEndpoint == "" {
+			configError("OTEL_TRACES_EXPORTER=otlp requires OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT")
+		}
 	}
+	if config.PushInterval <= 0 {
+		configError("PUSH_INTERVAL must be positive, got %v", config.PushInterval)
+	}
+	pushing := config.OTLPEndpoint != "" || config.PushgatewayURL != "" || config.RemoteWriteURL != ""
+	if !config.ServeMetrics && !pushing {
+		configError("SERVE_METRICS=false requires OTEL_EXPORTER_OTLP_ENDPOINT, PUSHGATEWAY_URL or REMOTE_WRITE_URL, the metrics would go nowhere")
+	}
+	if config.PushOnce && !pushing {
+		configError("PUSH_ONCE requires OTEL_EXPORTER_OTLP_ENDPOINT, PUSHGATEWAY_URL or REMOTE_WRITE_URL")
+	}
+	if config.RemoteWriteBearerToken != "" && config.RemoteWriteUsername != "" {
+		configError("REMOTE_WRITE_BEARER_TOKEN and REMOTE_WRITE_USERNAME are mutually exclusive")
+	}
+
+	return config, errors.Join(configErrors...)
+}
 
-	exporter := newExporter(config)
-	prometheus.MustRegister(exporter)
+// shutdownTimeout bounds how long in-flight requests may take to finish
+// once a shutdown was requested.
+const shutdownTimeout = 10 * time.Second
+
+// handlerOpts configures the metrics handlers. With OPENMETRICS the format
+// is negotiated through the Accept header, so Prometheus gets OpenMetrics and
+// everything else keeps receiving the text format.
+func handlerOpts(config Config) promhttp.HandlerOpts {
+	return promhttp.HandlerOpts{
+		ErrorLog:                            errorLogger(),
+		ErrorHandling:                       promhttp.ContinueOnError,
+		EnableOpenMetrics:                   config.OpenMetrics,
+		EnableOpenMetricsTextCreatedSamples: config.OpenMetrics && config.OpenMetricsCreated,
+	}
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "loadtest" {
+		os.Exit(runLoadTest(os.Args[2:]))
+	}
 
-	http.Handle(config.MetricsPath, promhttp.Handler())
+	configFile := flag.String("config.file", "", "YAML configuration file, environment variables override its values")
+	showVersion := flag.Bool("version", false, "print the version and exit")
+	registerCollectorFlags(flag.CommandLine)
+	flag.Parse()
+	if *showVersion {
+		fmt.Println(versionString())
+		return
+	}
+	if *configFile != "" {
+		if err := loadConfigFile(*configFile); err != nil {
+			fatal("Error loading configuration file", "file", *configFile, "error", err)
+		}
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	setupLogging(config)
+	if *configFile != "" {
+		slog.Info("Loaded configuration file", "file", *configFile)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if tracing = newTracer(config); tracing != nil {
+		go tracing.run(ctx)
+		slog.Info("Exporting traces", "endpoint", config.TracesEndpoint)
+	}
+	exportersCtx, cancelExporters := context.WithCancel(ctx)
+	exporters, err := newExporters(exportersCtx, config)
+	if err != nil {
+		cancelExporters()
+		fatal("Error starting exporter", "error", err)
+	}
+	prometheus.MustRegister(newBuildInfo())
+	reloader := newReloader(ctx, *configFile, exporters, cancelExporters)
+	go reloader.watchSignals()
+	if config.EnablePprof {
+		go servePprof(ctx, config.PprofAddress)
+	}
+
+	gatherers := prometheus.Gatherers{prometheus.DefaultGatherer}
+	if config.OutlineMetricsURL != "" {
+		gatherers = append(gatherers, newMetricsProxy(config))
+		slog.Info("Proxying Outline server metrics", "url", config.OutlineMetricsURL)
+	}
+	gatherer := func(ctx context.Context) prometheus.Gatherer {
+		return append(gatherers, reloader.gatherer(ctx))
+	}
+	if config.ServeMetrics {
+		metrics := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			promhttp.HandlerFor(gatherer(r.Context()), handlerOpts(config)).ServeHTTP(w, r)
+		})
+		http.Handle(config.MetricsPath, basicAuth(config.BasicAuthUsers, promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, metrics)))
+	}
+	pushers := newPushers(config)
+	if len(pushers) > 0 || tracing != nil {
+		prometheus.MustRegister(pushErrors)
+	}
+	// Run as a job: scrape Outline once, push the result and exit.
+	if config.PushOnce {
+		err := pushMetrics(ctx, gatherer(ctx), pushers)
+		if tracing != nil {
+			tracing.export(ctx)
+		}
+		if err != nil {
+			fatal("Error pushing metrics", "error", err)
+		}
+		slog.Info("Pushed metrics", "targets", len(pushers))
+		return
+	}
+	if len(pushers) > 0 {
+		go runPushers(ctx, config.PushInterval, gatherer, pushers)
+		slog.Info("Pushing metrics", "interval", config.PushInterval, "targets", len(pushers))
+	}
+	if len(config.ProbeTargets) > 0 {
+		http.Handle("/probe", basicAuth(config.BasicAuthUsers, newProber(config)))
+		slog.Info("Serving /probe", "targets", len(config.ProbeTargets))
+	}
+	http.HandleFunc("/-/invalidate", adminOnly(config.AdminToken, handleInvalidate(reloader.current)))
+	http.HandleFunc("/-/reload", adminOnly(config.AdminToken, reloader.handleReload))
+	http.HandleFunc("/errors", adminOnly(config.AdminToken, handleErrors(reloader.current)))
+	http.Handle("/status", basicAuth(config.BasicAuthUsers, handleStatus(reloader.current)))
+	http.Handle("/api/stats", basicAuth(config.BasicAuthUsers, handleStats(reloader.current)))
 	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	links := `<p><a href="` + config.MetricsPath + `">Metrics</a></p>`
+	if !config.ServeMetrics {
+		links = `<p>Metrics are pushed, not served</p>`
+	}
+	http.Handle("/", basicAuth(config.BasicAuthUsers, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
 			<head><title>Outline Wiki Exporter</title></head>
 			<body>
 			<h1>Outline Wiki Exporter</h1>
-			<p><a href="` + config.MetricsPath + `">Metrics</a></p>
+			` + links + `
 			</body>
 			</html>`))
-	})
+	})))
 
-	log.Printf("Starting Outline Wiki exporter on %s", config.ListenAddress)
-	log.Printf("Using page limit of %d items", config.PageLimit)
+	slog.Info("Starting Outline Wiki exporter", "version", version, "address", config.ListenAddress, "profile", config.Profile, "page_limit", config.PageLimit)
 	if config.Debug {
-		log.Printf("Debug mode enabled")
+		slog.Info("Debug mode enabled")
+	}
+
+	server := &http.Server{Addr: config.ListenAddress}
+	go func() {
+		var err error
+		if config.ListenTLSCert != "" {
+			slog.Info("Serving over HTTPS", "certificate", config.ListenTLSCert)
+			err = server.ListenAndServeTLS(config.ListenTLSCert, config.ListenTLSKey)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			fatal("Error serving HTTP", "error", err)
+		}
+	}()
+
+	// On SIGINT or SIGTERM the context is cancelled, which aborts in-flight
+	// Outline requests so running scrapes return promptly, and the server
+	// stops accepting connections and waits for them.
+	<-ctx.Done()
+	stop()
+	slog.Info("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error during shutdown", "error", err)
+	}
+	// The spans of the last scrapes are still waiting for the next export.
+	if tracing != nil {
+		tracing.export(shutdownCtx)
 	}
-	log.Fatal(http.ListenAndServe(config.ListenAddress, nil))
 }
 
 func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
+	if value, ok := lookupEnv(key); ok {
 		return value
 	}
 	return fallback
 }
 
 func getDuration(key string, fallback time.Duration) time.Duration {
-	if value, ok := os.LookupEnv(key); ok {
-		if duration, err := time.ParseDuration(value); err == nil {
+	if value, ok := lookupEnv(key); ok {
+		if duration, err := parseDuration(value); err == nil {
 			return duration
 		}
-		log.Printf("Invalid duration %s=%s, using %s", key, value, fallback)
+		slog.Warn("Invalid duration, using the default", "key", key, "value", value, "default", fallback)
+	}
+	return fallback
+}
+
+// parseDuration accepts everything time.ParseDuration does plus a plain "d"
+// suffix for days, which is how staleness thresholds are usually expressed.
+func parseDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	return time.ParseDuration(value)
+}
+
+// formatDuration is the inverse of parseDuration, writing whole days as "90d"
+// and whole hours as "12h".
+func formatDuration(d time.Duration) string {
+	const day = 24 * time.Hour
+	switch {
+	case d > 0 && d%day == 0:
+		return strconv.Itoa(int(d/day)) + "d"
+	case d > 0 && d%time.Hour == 0:
+		return strconv.Itoa(int(d/time.Hour)) + "h"
+	}
+	return d.String()
+}
+
+func getDurations(key string, fallback []time.Duration) []time.Duration {
+	value, ok := lookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	var durations []time.Duration
+	for _, part := range strings.Split(value, ",") {
+		duration, err := parseDuration(strings.TrimSpace(part))
+		if err != nil {
+			slog.Warn("Invalid durations, using the default", "key", key, "value", value, "default", fallback)
+			return fallback
+		}
+		durations = append(durations, duration)
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return durations
+}
+
+// getBuckets parses comma-separated histogram upper bounds.
+func getBuckets(key string, fallback []float64) []float64 {
+	value, ok := lookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	var buckets []float64
+	for _, part := range strings.Split(value, ",") {
+		bucket, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			slog.Warn("Invalid buckets, using the default", "key", key, "value", value, "default", fallback)
+			return fallback
+		}
+		buckets = append(buckets, bucket)
+	}
+	sort.Float64s(buckets)
+	return buckets
+}
+
+func getLocation(key string, fallback *time.Location) *time.Location {
+	if value, ok := lookupEnv(key); ok {
+		if location, err := time.LoadLocation(value); err == nil {
+			return location
+		}
+		slog.Warn("Invalid timezone, using the default", "key", key, "value", value, "default", fallback)
+	}
+	return fallback
+}
+
+func getChoice(key, fallback string, choices ...string) string {
+	if value, ok := lookupEnv(key); ok {
+		value = strings.ToLower(strings.TrimSpace(value))
+		for _, choice := range choices {
+			if value == choice {
+				return value
+			}
+		}
+		slog.Warn("Invalid value, using the default", "key", key, "value", value, "choices", strings.Join(choices, ", "), "default", fallback)
 	}
 	return fallback
 }
 
 func getInt(key string, fallback int) int {
-	if value, ok := os.LookupEnv(key); ok {
+	if value, ok := lookupEnv(key); ok {
 		var intValue int
 		if _, err := fmt.Sscanf(value, "%d", &intValue); err == nil {
 			return intValue
 		}
-		log.Printf("Invalid int %s=%s, using %d", key, value, fallback)
+		slog.Warn("Invalid int, using the default", "key", key, "value", value, "default", fallback)
+	}
+	return fallback
+}
+
+// getList splits a comma separated value, dropping empty entries.
+func getList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getSavedSearches parses "name=query" pairs separated by semicolons, so that
+// queries themselves may contain commas.
+func getSavedSearches(key string) []SavedSearch {
+	var searches []SavedSearch
+	for _, entry := range strings.Split(getEnv(key, ""), ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, query, found := strings.Cut(entry, "=")
+		name, query = strings.TrimSpace(name), strings.TrimSpace(query)
+		if !found || name == "" || query == "" {
+			slog.Warn("Invalid saved search, ignoring", "key", key, "entry", entry)
+			continue
+		}
+		searches = append(searches, SavedSearch{Name: name, Query: query})
+	}
+	return searches
+}
+
+// getProbeTargets parses `url=key` pairs separated by `;`. Several keys can
+// be given for one target, separated by commas.
+func getProbeTargets(key string) map[string][]string {
+	targets := make(map[string][]string)
+	for _, entry := range strings.Split(getEnv(key, ""), ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		target, keys, found := strings.Cut(entry, "=")
+		target = strings.TrimRight(strings.TrimSpace(target), "/")
+		var apiKeys []string
+		for _, apiKey := range strings.Split(keys, ",") {
+			if apiKey = strings.TrimSpace(apiKey); apiKey != "" {
+				apiKeys = append(apiKeys, apiKey)
+			}
+		}
+		if !found || target == "" || len(apiKeys) == 0 {
+			slog.Warn("Invalid probe target, ignoring", "key", key)
+			continue
+		}
+		targets[target] = apiKeys
+	}
+	return targets
+}
+
+// getBasicAuthUsers parses `user=bcrypt-hash` pairs separated by `;`.
+func getBasicAuthUsers(key string) map[string]string {
+	users := make(map[string]string)
+	for _, entry := range strings.Split(getEnv(key, ""), ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		user, hash, found := strings.Cut(entry, "=")
+		user, hash = strings.TrimSpace(user), strings.TrimSpace(hash)
+		if _, err := bcrypt.Cost([]byte(hash)); !found || user == "" || err != nil {
+			configError("invalid %s entry for user %q, expected user=bcrypt-hash", key, user)
+			continue
+		}
+		users[user] = hash
+	}
+	return users
+}
+
+// getKeyValues parses key=value pairs separated by commas, with URL encoded
+// values, the form of the headers of the OpenTelemetry SDKs.
+func getKeyValues(key string) map[string]string {
+	headers := make(map[string]string)
+	for _, entry := range strings.Split(getEnv(key, ""), ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, value, found := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if unescaped, err := url.QueryUnescape(strings.TrimSpace(value)); err == nil {
+			value = unescaped
+		}
+		if !found || name == "" {
+			configError("invalid %s entry %q, expected key=value", key, entry)
+			continue
+		}
+		headers[name] = value
+	}
+	return headers
+}
+
+func getFloat(key string, fallback float64) float64 {
+	if value, ok := lookupEnv(key); ok {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+		slog.Warn("Invalid float, using the default", "key", key, "value", value, "default", fallback)
 	}
 	return fallback
 }
 
 func getBool(key string, fallback bool) bool {
-	if value, ok := os.LookupEnv(key); ok {
+	if value, ok := lookupEnv(key); ok {
 		switch strings.ToLower(value) {
 		case "true", "1", "t", "yes", "y":
 			return true
 		case "false", "0", "f", "no", "n":
 			return false
 		}
-		log.Printf("Invalid bool %s=%s, using %t", key, value, fallback)
+		slog.Warn("Invalid bool, using the default", "key", key, "value", value, "default", fallback)
 	}
 	return fallback
 }
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxMembershipLookups bounds the concurrent requests made to list the
+// memberships of collections.
+const maxMembershipLookups = 4
+
+type CollectionMembership struct {
+	ID         string `json:"id"`
+	UserID     string `json:"userId"`
+	Permission string `json:"permission"`
+}
+
+// listKey implements listKeyed: the memberships come together with the
+// users they refer to.
+func (CollectionMembership) listKey() string {
+	return "memberships"
+}
+
+type CollectionGroupMembership struct {
+	ID         string `json:"id"`
+	GroupID    string `json:"groupId"`
+	Permission string `json:"permission"`
+}
+
+// listKey implements listKeyed: the memberships come together with the
+// groups they refer to.
+func (CollectionGroupMembership) listKey() string {
+	return "groupMemberships"
+}
+
+// membershipCounts is the number of users and groups given access to a
+// collection explicitly.
+type membershipCounts struct {
+	collection Collection
+	users      int
+	groups     int
+}
+
+// fetchMemberships lists the user and group memberships of every collection.
+// Memberships are listed per collection, so the collections are listed here
+// as well rather than waiting on the collections resource.
+func (e *Exporter) fetchMemberships(ctx context.Context) ([]membershipCounts, error) {
+	collections, err := fetchAll[Collection](ctx, e, "/api/collections.list", nil)
+	if err != nil {
+		return nil, fmt.Errorf("list collections: %w", err)
+	}
+	collections = e.filterCollections(collections)
+
+	counts := make([]membershipCounts, len(collections))
+	var g errgroup.Group
+	g.SetLimit(maxMembershipLookups)
+	for i, collection := range collections {
+		g.Go(func() error {
+			params := map[string]any{"id": collection.ID}
+			users, err := fetchAll[CollectionMembership](ctx, e, "/api/collections.memberships", params)
+			if err != nil {
+				return fmt.Errorf("collection %s: %w", collection.ID, err)
+			}
+			groups, err := fetchAll[CollectionGroupMembership](ctx, e, "/api/collections.group_memberships", params)
+			if err != nil {
+				return fmt.Errorf("collection %s: %w", collection.ID, err)
+			}
+			counts[i] = membershipCounts{collection: collection, users: len(users), groups: len(groups)}
+			return nil
+		})
+	}
+	return counts, g.Wait()
+}
+
+// collectMemberships exports how many users and groups were given access to
+// each collection. A private collection without either is reachable by
+// admins only.
+func (e *Exporter) collectMemberships(ch chan<- prometheus.Metric, counts []membershipCounts) {
+	for _, count := range counts {
+		ch <- prometheus.MustNewConstMetric(e.collectionMembers, prometheus.GaugeValue,
+			float64(count.users), count.collection.ID, count.collection.Name)
+		ch <- prometheus.MustNewConstMetric(e.collectionGroups, prometheus.GaugeValue,
+			float64(count.groups), count.collection.ID, count.collection.Name)
+	}
+}
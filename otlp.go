@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// otlpStartTime is the start of the cumulative sums of counters without a
+// created timestamp.
+var otlpStartTime = time.Now()
+
+// otlpPusher sends the metrics to an OpenTelemetry collector with OTLP over
+// HTTP, using the JSON encoding so no protobuf code needs to be generated.
+type otlpPusher struct {
+	url      string
+	headers  map[string]string
+	resource otlpResource
+	client   *http.Client
+}
+
+func newOTLPPusher(config Config) *otlpPusher {
+	return &otlpPusher{
+		url:      strings.TrimSuffix(config.OTLPEndpoint, "/") + "/v1/metrics",
+		headers:  config.OTLPHeaders,
+		resource: newOTLPResource(config),
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (p *otlpPusher) name() string {
+	return "otlp"
+}
+
+func (p *otlpPusher) push(ctx context.Context, families []*dto.MetricFamily) error {
+	request := otlpMetricsRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: p.resource,
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{Name: "outline_exporter", Version: version},
+			Metrics: otlpMetrics(families, time.Now()),
+		}},
+	}}}
+	return postOTLP(ctx, p.client, p.url, p.headers, request)
+}
+
+// postOTLP sends an OTLP/HTTP JSON request.
+func postOTLP(ctx context.Context, client *http.Client, url string, headers map[string]string, request any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+	responseData, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyLength))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, responseData)
+	}
+	return nil
+}
+
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Gauge       *otlpGauge     `json:"gauge,omitempty"`
+	Sum         *otlpSum       `json:"sum,omitempty"`
+	Histogram   *otlpHistogram `json:"histogram,omitempty"`
+	Summary     *otlpSummary   `json:"summary,omitempty"`
+}
+
+// otlpCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE, how Prometheus
+// counters and histograms accumulate.
+const otlpCumulative = 2
+
+type otlpGauge struct {
+	DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                      `json:"aggregationTemporality"`
+}
+
+type otlpSummary struct {
+	DataPoints []otlpSummaryDataPoint `json:"dataPoints"`
+}
+
+// 64 bit integers are encoded as strings in the JSON encoding of OTLP.
+type otlpNumberDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsDouble          otlpDouble      `json:"asDouble"`
+}
+
+type otlpHistogramDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	Count             string          `json:"count"`
+	Sum               otlpDouble      `json:"sum"`
+	BucketCounts      []string        `json:"bucketCounts"`
+	ExplicitBounds    []otlpDouble    `json:"explicitBounds"`
+}
+
+type otlpSummaryDataPoint struct {
+	Attributes     []otlpAttribute     `json:"attributes"`
+	TimeUnixNano   string              `json:"timeUnixNano"`
+	Count          string              `json:"count"`
+	Sum            otlpDouble          `json:"sum"`
+	QuantileValues []otlpQuantileValue `json:"quantileValues"`
+}
+
+type otlpQuantileValue struct {
+	Quantile otlpDouble `json:"quantile"`
+	Value    otlpDouble `json:"value"`
+}
+
+// otlpDouble encodes NaN and infinities the way the protobuf JSON mapping
+// does, encoding/json refuses them.
+type otlpDouble float64
+
+func (d otlpDouble) MarshalJSON() ([]byte, error) {
+	switch {
+	case math.IsNaN(float64(d)):
+		return []byte(`"NaN"`), nil
+	case math.IsInf(float64(d), 1):
+		return []byte(`"Infinity"`), nil
+	case math.IsInf(float64(d), -1):
+		return []byte(`"-Infinity"`), nil
+	}
+	return json.Marshal(float64(d))
+}
+
+func newOTLPResource(config Config) otlpResource {
+	attributes := map[string]string{
+		"service.name":    config.OTLPServiceName,
+		"service.version": version,
+	}
+	if config.InstanceName != "" {
+		attributes["service.instance.id"] = config.InstanceName
+	}
+	return otlpResource{Attributes: otlpAttributes(attributes)}
+}
+
+func otlpAttributes(values map[string]string) []otlpAttribute {
+	attributes := make([]otlpAttribute, 0, len(values))
+	for key, value := range values {
+		attributes = append(attributes, otlpAttribute{Key: key, Value: otlpAnyValue{StringValue: value}})
+	}
+	sort.Slice(attributes, func(i, j int) bool { return attributes[i].Key < attributes[j].Key })
+	return attributes
+}
+
+// otlpLabels drops empty labels, which Prometheus treats as absent.
+func otlpLabels(labels []*dto.LabelPair) []otlpAttribute {
+	attributes := make([]otlpAttribute, 0, len(labels))
+	for _, label := range labels {
+		if label.GetValue() == "" {
+			continue
+		}
+		attributes = append(attributes, otlpAttribute{Key: label.GetName(), Value: otlpAnyValue{StringValue: label.GetValue()}})
+	}
+	return attributes
+}
+
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// otlpMetrics converts gathered metric families to OTLP metrics. Counters
+// become monotonic cumulative sums, untyped metrics gauges.
+func otlpMetrics(families []*dto.MetricFamily, now time.Time) []otlpMetric {
+	timestamp := otlpTime(now)
+	start := otlpTime(otlpStartTime)
+	metrics := make([]otlpMetric, 0, len(families))
+	for _, family := range families {
+		metric := otlpMetric{Name: family.GetName(), Description: family.GetHelp()}
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			sum := &otlpSum{AggregationTemporality: otlpCumulative, IsMonotonic: true}
+			for _, m := range family.GetMetric() {
+				point := otlpNumberDataPoint{Attributes: otlpLabels(m.GetLabel()), StartTimeUnixNano: start, TimeUnixNano: timestamp, AsDouble: otlpDouble(m.GetCounter().GetValue())}
+				if created := m.GetCounter().GetCreatedTimestamp(); created != nil {
+					point.StartTimeUnixNano = otlpTime(created.AsTime())
+				}
+				sum.DataPoints = append(sum.DataPoints, point)
+			}
+			metric.Sum = sum
+		case dto.MetricType_HISTOGRAM:
+			histogram := &otlpHistogram{AggregationTemporality: otlpCumulative}
+			for _, m := range family.GetMetric() {
+				histogram.DataPoints = append(histogram.DataPoints, otlpHistogramPoint(m, start, timestamp))
+			}
+			metric.Histogram = histogram
+		case dto.MetricType_SUMMARY:
+			summary := &otlpSummary{}
+			for _, m := range family.GetMetric() {
+				point := otlpSummaryDataPoint{
+					Attributes:   otlpLabels(m.GetLabel()),
+					TimeUnixNano: timestamp,
+					Count:        strconv.FormatUint(m.GetSummary().GetSampleCount(), 10),
+					Sum:          otlpDouble(m.GetSummary().GetSampleSum()),
+				}
+				for _, quantile := range m.GetSummary().GetQuantile() {
+					point.QuantileValues = append(point.QuantileValues, otlpQuantileValue{Quantile: otlpDouble(quantile.GetQuantile()), Value: otlpDouble(quantile.GetValue())})
+				}
+				summary.DataPoints = append(summary.DataPoints, point)
+			}
+			metric.Summary = summary
+		default:
+			gauge := &otlpGauge{}
+			for _, m := range family.GetMetric() {
+				value := m.GetGauge().GetValue()
+				if family.GetType() == dto.MetricType_UNTYPED {
+					value = m.GetUntyped().GetValue()
+				}
+				gauge.DataPoints = append(gauge.DataPoints, otlpNumberDataPoint{Attributes: otlpLabels(m.GetLabel()), TimeUnixNano: timestamp, AsDouble: otlpDouble(value)})
+			}
+			metric.Gauge = gauge
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics
+}
+
+// otlpHistogramPoint turns the cumulative buckets of Prometheus into the
+// per-bucket counts of OTLP, whose last bucket is the implicit +Inf one.
+func otlpHistogramPoint(m *dto.Metric, start, timestamp string) otlpHistogramDataPoint {
+	histogram := m.GetHistogram()
+	point := otlpHistogramDataPoint{
+		Attributes:        otlpLabels(m.GetLabel()),
+		StartTimeUnixNano: start,
+		TimeUnixNano:      timestamp,
+		Count:             strconv.FormatUint(histogram.GetSampleCount(), 10),
+		Sum:               otlpDouble(histogram.GetSampleSum()),
+	}
+	if created := histogram.GetCreatedTimestamp(); created != nil {
+		point.StartTimeUnixNano = otlpTime(created.AsTime())
+	}
+	var previous uint64
+	for _, bucket := range histogram.GetBucket() {
+		if math.IsInf(bucket.GetUpperBound(), 1) {
+			continue
+		}
+		point.ExplicitBounds = append(point.ExplicitBounds, otlpDouble(bucket.GetUpperBound()))
+		point.BucketCounts = append(point.BucketCounts, strconv.FormatUint(bucket.GetCumulativeCount()-previous, 10))
+		previous = bucket.GetCumulativeCount()
+	}
+	point.BucketCounts = append(point.BucketCounts, strconv.FormatUint(histogram.GetSampleCount()-previous, 10))
+	return point
+}
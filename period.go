@@ -0,0 +1,95 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const day = 24 * time.Hour
+
+// periodStart returns the start of a window of the given length ending at
+// now. Windows made of whole days are aligned to midnight in loc, so that a
+// "30d" window covers today plus the 30 previous calendar days of the
+// business timezone rather than a rolling 720h ending at an arbitrary time.
+func periodStart(now time.Time, window time.Duration, loc *time.Location) time.Time {
+	if window <= 0 || window%day != 0 {
+		return now.Add(-window)
+	}
+
+	local := now.In(loc).AddDate(0, 0, -int(window/day))
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+}
+
+// cutoff is periodStart for the current time in the configured timezone.
+func (e *Exporter) cutoff(window time.Duration) time.Time {
+	return periodStart(time.Now(), window, e.config.Location)
+}
+
+// age returns the seconds elapsed since t. Timestamps in the future, caused
+// by clock skew on the Outline server or by imported content, are clamped to
+// zero instead of producing negative ages; detectClockSkew counts them.
+func (e *Exporter) age(t time.Time) float64 {
+	return max(time.Since(t).Seconds(), 0)
+}
+
+// detectClockSkew counts the timestamps of a freshly fetched snapshot that
+// are in the future, once per snapshot however many metrics they appear in.
+func (e *Exporter) detectClockSkew(snap *snapshot) {
+	now := time.Now()
+	counts := make(map[string]int)
+	future := func(entity string, times ...time.Time) {
+		for _, t := range times {
+			if t.After(now) {
+				e.logger.Debug("Timestamp is in the future, clamping age to 0", "entity", entity, "timestamp", t)
+				counts[entity]++
+			}
+		}
+	}
+	for _, collection := range snap.collections {
+		future("collection", collection.CreatedAt)
+	}
+	for _, document := range snap.documents {
+		future("document", document.CreatedAt, document.UpdatedAt)
+	}
+	for _, template := range snap.templates {
+		future("template", template.UpdatedAt)
+	}
+	for _, user := range snap.users {
+		future("user", user.CreatedAt, user.LastActiveAt)
+	}
+	for _, group := range snap.groups {
+		future("group", group.CreatedAt)
+	}
+	for _, share := range snap.shares {
+		future("share", share.CreatedAt)
+	}
+	for _, operation := range snap.fileOperations {
+		future("file_operation", operation.CreatedAt)
+	}
+	for entity, count := range counts {
+		e.clockSkewDetections.WithLabelValues(entity).Add(float64(count))
+	}
+}
+
+// collectTimestamp exports t as a Unix timestamp gauge. Outline returns null
+// for lifecycle events that haven't happened, which decodes to the zero time,
+// so nothing is exported in that case rather than a bogus year-1 timestamp.
+func collectTimestamp(ch chan<- prometheus.Metric, desc *prometheus.Desc, t time.Time, labelValues ...string) {
+	if t.IsZero() {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(t.Unix()), labelValues...)
+}
+
+// collectAge exports t as an age gauge, an absolute timestamp gauge or both,
+// depending on AGE_METRICS. Timestamps stay constant between scrapes of
+// unchanged data and let PromQL compute ages with time() - x.
+func (e *Exporter) collectAge(ch chan<- prometheus.Metric, age, timestamp *prometheus.Desc, t time.Time, labelValues ...string) {
+	if e.config.AgeMetrics != "timestamp" {
+		ch <- prometheus.MustNewConstMetric(age, prometheus.GaugeValue, e.age(t), labelValues...)
+	}
+	if e.config.AgeMetrics != "age" {
+		ch <- prometheus.MustNewConstMetric(timestamp, prometheus.GaugeValue, float64(t.Unix()), labelValues...)
+	}
+}
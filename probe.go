@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// prober serves /probe?target=<url>, scraping another Outline instance in
+// the style of the blackbox exporter. Only targets listed in PROBE_TARGETS
+// can be probed, so the API keys are never sent anywhere else. Each target
+// keeps its own exporter between probes so change counters and view rates
+// work as they do on /metrics.
+type prober struct {
+	config Config
+
+	mu        sync.Mutex
+	exporters map[string]*Exporter
+}
+
+func newProber(config Config) *prober {
+	return &prober{config: config, exporters: make(map[string]*Exporter)}
+}
+
+func (p *prober) exporter(target string) (*Exporter, bool) {
+	keys, ok := p.config.ProbeTargets[target]
+	if !ok {
+		return nil, false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if exporter, ok := p.exporters[target]; ok {
+		return exporter, true
+	}
+
+	config := p.config
+	config.OutlineAPIURL = target
+	config.OutlineAPIKeys = keys
+	config.ScrapeInterval = 0
+	exporter := newExporter(config)
+	go exporter.watchCapabilities(true)
+
+	p.exporters[target] = exporter
+	slog.Info("Probing new target", "target", target)
+	return exporter, true
+}
+
+func (p *prober) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := strings.TrimRight(r.URL.Query().Get("target"), "/")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+	exporter, ok := p.exporter(target)
+	if !ok {
+		http.Error(w, "unknown target, add it to PROBE_TARGETS", http.StatusBadRequest)
+		return
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(boundExporter{exporter, r.Context()})
+	promhttp.HandlerFor(registry, handlerOpts(p.config)).ServeHTTP(w, r)
+}
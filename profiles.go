@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// scrapeProfiles bundle defaults for SCRAPE_PROFILE so new users don't have
+// to pick their way through every option. Each entry is only a default:
+// explicitly set environment variables always win.
+var scrapeProfiles = map[string]map[string]string{
+	// light keeps API load and series count low on big wikis.
+	"light": {
+		"COLLECTOR_COMMENTS":       "false",
+		"PER_USER_METRICS":         "false",
+		"DOCUMENT_ACTIVITY_WINDOW": "30d",
+		"OWNER_FIELD":              "",
+	},
+	// standard is the behavior without any profile.
+	"standard": {},
+	// deep turns on every collector and content analysis.
+	"deep": {
+		"COLLECTOR_COMMENTS": "true",
+		"PER_USER_METRICS":   "true",
+		"AGE_METRICS":        "both",
+		"TAG_PATTERN":        `(?:^|\s)#([A-Za-z][\w-]*)`,
+	},
+}
+
+var profileDefaults map[string]string
+
+// lookupEnv returns the value of an environment variable, falling back to
+// the configuration file and then to the default of the selected scrape
+// profile. Every option can also be read from the file named by <KEY>_FILE,
+// so credentials can come from Docker or Kubernetes secrets instead of the
+// environment.
+func lookupEnv(key string) (string, bool) {
+	if value, ok := os.LookupEnv(key); ok {
+		return value, true
+	}
+	if path, ok := os.LookupEnv(key + "_FILE"); ok {
+		return readSecret(key, path)
+	}
+	if value, ok := fileValues[key]; ok {
+		return value, true
+	}
+	if path, ok := fileValues[key+"_FILE"]; ok {
+		return readSecret(key, path)
+	}
+	value, ok := profileDefaults[key]
+	return value, ok
+}
+
+// readSecret reads a value from a file, without the trailing newline most
+// editors and secret stores add. The file is read on every load so rotated
+// secrets are picked up by a reload.
+func readSecret(key, path string) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		configError("reading %s_FILE: %v", key, err)
+		return "", false
+	}
+	return strings.TrimRight(string(data), "\r\n"), true
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// metricsProxy re-exposes the metrics of Outline's own Prometheus endpoint,
+// prefixed so they can't collide with the API-derived metrics. It is
+// gathered next to the exporter's registry, so a single scrape target
+// covers both.
+type metricsProxy struct {
+	url    string
+	prefix string
+	client *http.Client
+}
+
+func newMetricsProxy(config Config) *metricsProxy {
+	return &metricsProxy{
+		url:    config.OutlineMetricsURL,
+		prefix: config.OutlineMetricsPrefix,
+		client: &http.Client{Timeout: config.ScrapeTimeout, Transport: newTransport(config)},
+	}
+}
+
+func (p *metricsProxy) Gather() ([]*dto.MetricFamily, error) {
+	req, err := http.NewRequest(http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("outline metrics: %w", err)
+	}
+	req.Header.Set("Accept", "text/plain")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("outline metrics: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("outline metrics: status %d", resp.StatusCode)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("outline metrics: parse: %w", err)
+	}
+
+	result := make([]*dto.MetricFamily, 0, len(families))
+	for name, family := range families {
+		prefixed := p.prefix + name
+		family.Name = &prefixed
+		result = append(result, family)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].GetName() < result[j].GetName() })
+	return result, nil
+}
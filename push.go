@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// metricsPusher sends the exporter's metrics to a system that doesn't
+// scrape, e.g. an OpenTelemetry collector.
+type metricsPusher interface {
+	name() string
+	push(ctx context.Context, families []*dto.MetricFamily) error
+}
+
+var pushErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "outline_exporter_push_errors_total",
+	Help: "Failed pushes of the metrics, by destination",
+}, []string{"target"})
+
+func newPushers(config Config) []metricsPusher {
+	var pushers []metricsPusher
+	if config.OTLPEndpoint != "" {
+		pushers = append(pushers, newOTLPPusher(config))
+	}
+	if config.PushgatewayURL != "" {
+		pushers = append(pushers, newPushgatewayPusher(config))
+	}
+	if config.RemoteWriteURL != "" {
+		pushers = append(pushers, newRemoteWritePusher(config))
+	}
+	return pushers
+}
+
+// runPushers gathers the metrics every PUSH_INTERVAL, exactly as a scrape of
+// /metrics would, and hands them to each pusher until ctx is cancelled.
+func runPushers(ctx context.Context, interval time.Duration, gatherer func(ctx context.Context) prometheus.Gatherer, pushers []metricsPusher) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		pushMetrics(ctx, gatherer(ctx), pushers)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// pushMetrics gathers the metrics once and pushes them everywhere, returning
+// the errors of the pushes that failed. A failed Outline scrape isn't one of
+// them: outline_up tells about it.
+func pushMetrics(ctx context.Context, gatherer prometheus.Gatherer, pushers []metricsPusher) error {
+	families, err := gatherer.Gather()
+	if err != nil {
+		// Gatherers return what they could collect along with the error.
+		slog.Warn("Error gathering metrics to push", "error", err)
+	}
+	var errs []error
+	for _, pusher := range pushers {
+		if err := pusher.push(ctx, families); err != nil {
+			pushErrors.WithLabelValues(pusher.name()).Inc()
+			slog.Error("Error pushing metrics", "target", pusher.name(), "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", pusher.name(), err))
+			continue
+		}
+		slog.Debug("Pushed metrics", "target", pusher.name(), "families", len(families))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// pushgatewayPusher replaces the metrics of the exporter's group on a
+// Pushgateway, for networks Prometheus can't reach into.
+type pushgatewayPusher struct {
+	url      string
+	job      string
+	username string
+	password string
+	grouping map[string]string
+}
+
+func newPushgatewayPusher(config Config) *pushgatewayPusher {
+	p := &pushgatewayPusher{url: config.PushgatewayURL, job: config.PushgatewayJob, grouping: config.PushgatewayGrouping}
+	// Credentials in the URL are sent as basic auth, the push client
+	// doesn't read them from there.
+	if u, err := url.Parse(config.PushgatewayURL); err == nil && u.User != nil {
+		p.username = u.User.Username()
+		p.password, _ = u.User.Password()
+		u.User = nil
+		p.url = u.String()
+	}
+	return p
+}
+
+func (p *pushgatewayPusher) name() string {
+	return "pushgateway"
+}
+
+// push uses PUT, so series that disappeared from the exporter are removed
+// from the group instead of lingering with their last value.
+func (p *pushgatewayPusher) push(ctx context.Context, families []*dto.MetricFamily) error {
+	pusher := push.New(p.url, p.job).
+		Gatherer(prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) { return families, nil }))
+	for name, value := range p.grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+	if p.username != "" {
+		pusher = pusher.BasicAuth(p.username, p.password)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	return pusher.PushContext(ctx)
+}
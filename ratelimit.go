@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRetryAfter caps the wait requested by a Retry-After header, so a
+// misbehaving proxy can't stall a scrape indefinitely.
+const maxRetryAfter = time.Minute
+
+// rateLimiter is a token bucket spacing out the requests made to Outline to
+// API_RATE_LIMIT per second, allowing bursts of API_RATE_BURST.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns nil, which never waits, unless API_RATE_LIMIT is set.
+func newRateLimiter(config Config) *rateLimiter {
+	if config.APIRateLimit <= 0 {
+		return nil
+	}
+	burst := float64(config.APIRateBurst)
+	if burst < 1 {
+		burst = math.Max(1, math.Floor(config.APIRateLimit))
+	}
+	return &rateLimiter{rate: config.APIRateLimit, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// wait blocks until a request may be made or the context is cancelled.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	// The token is taken right away, even if it is only available later, so
+	// concurrent callers queue up in order.
+	l.tokens--
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// parseRetryAfter reads a Retry-After header, given either in seconds or as
+// an HTTP date. It returns 0 when the header is missing or invalid.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
+
+// rateLimited reports whether Outline rejected the request with 429, and
+// how long it asked to wait before retrying.
+func rateLimited(err error) (time.Duration, bool) {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	return min(apiErr.RetryAfter, maxRetryAfter), true
+}
+
+// recordRateLimit exports the rate limit headers Outline sends along with
+// its responses, so the remaining budget of each key is visible before
+// requests start getting rejected.
+func (e *Exporter) recordRateLimit(label string, header http.Header) {
+	if limit, err := strconv.ParseFloat(header.Get("RateLimit-Limit"), 64); err == nil {
+		e.apiRateLimit.WithLabelValues(label).Set(limit)
+	}
+	if remaining, err := strconv.ParseFloat(header.Get("RateLimit-Remaining"), 64); err == nil {
+		e.apiRateLimitRemaining.WithLabelValues(label).Set(remaining)
+	}
+	if reset, ok := parseRateLimitReset(header.Get("RateLimit-Reset"), time.Now()); ok {
+		e.apiRateLimitReset.WithLabelValues(label).Set(float64(reset.Unix()))
+	}
+}
+
+// jsDateLayout is how Outline formats RateLimit-Reset, JavaScript's
+// Date.toString() without the trailing time zone name.
+const jsDateLayout = "Mon Jan 02 2006 15:04:05 GMT-0700"
+
+// parseRateLimitReset reads a RateLimit-Reset header, given as seconds until
+// the window resets, as a Unix timestamp, as an HTTP date or as a JavaScript
+// date.
+func parseRateLimitReset(value string, now time.Time) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		// Anything past a year is a timestamp rather than a delay.
+		if seconds > 365*24*60*60 {
+			return time.Unix(int64(seconds), 0), true
+		}
+		return now.Add(time.Duration(seconds * float64(time.Second))), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date, true
+	}
+	if i := strings.Index(value, " ("); i >= 0 {
+		value = value[:i]
+	}
+	if date, err := time.Parse(jsDateLayout, value); err == nil {
+		return date, true
+	}
+	return time.Time{}, false
+}
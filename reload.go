@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// reloader owns the running exporters and replaces them when the
+// configuration is reloaded with SIGHUP or POST /-/reload. Settings of the
+// HTTP server itself (listen address, TLS, basic auth, metrics path, probe
+// targets) only change on restart.
+type reloader struct {
+	ctx        context.Context
+	configFile string
+
+	// reloading serializes reloads, which probe Outline with a strict
+	// compatibility check. mu only guards the running exporters, so scrapes
+	// aren't held up meanwhile.
+	reloading sync.Mutex
+	mu        sync.Mutex
+	exporters []*Exporter
+	cancel    context.CancelFunc
+}
+
+// newReloader starts the initial exporters, which must have been created with
+// a context cancelled by cancel so the first reload stops them.
+func newReloader(ctx context.Context, configFile string, exporters []*Exporter, cancel context.CancelFunc) *reloader {
+	r := &reloader{ctx: ctx, configFile: configFile}
+	r.swap(exporters, cancel)
+	return r
+}
+
+// load reads the configuration file, if any, and the environment.
+func (r *reloader) load() (Config, error) {
+	if r.configFile != "" {
+		if err := loadConfigFile(r.configFile); err != nil {
+			return Config{}, err
+		}
+	}
+	return loadConfig()
+}
+
+// reload builds exporters from the current configuration and swaps them in.
+// An invalid configuration is rejected and the running exporters are kept.
+func (r *reloader) reload() error {
+	r.reloading.Lock()
+	defer r.reloading.Unlock()
+
+	previousFile := fileValues
+	config, err := r.load()
+	if err != nil {
+		fileValues = previousFile
+		return err
+	}
+	setupLogging(config)
+	ctx, cancel := context.WithCancel(r.ctx)
+	exporters, err := newExporters(ctx, config)
+	if err != nil {
+		cancel()
+		fileValues = previousFile
+		return err
+	}
+	r.mu.Lock()
+	r.swap(exporters, cancel)
+	r.mu.Unlock()
+	return nil
+}
+
+// swap stops the background work of the running exporters and starts the
+// given ones. Must be called with r.mu held, except from the constructor.
+func (r *reloader) swap(exporters []*Exporter, cancel context.CancelFunc) {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	for _, exporter := range exporters {
+		exporter.start()
+	}
+	r.exporters, r.cancel = exporters, cancel
+}
+
+// gatherer registers the running exporters in a registry of their own for a
+// single /metrics request, bound to its context.
+func (r *reloader) gatherer(ctx context.Context) prometheus.Gatherer {
+	registry := prometheus.NewRegistry()
+	for _, exporter := range r.current() {
+		exporter.registerer(registry).MustRegister(boundExporter{exporter, ctx})
+	}
+	return registry
+}
+
+func (r *reloader) current() []*Exporter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.exporters
+}
+
+// watchSignals reloads the configuration on every SIGHUP.
+func (r *reloader) watchSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for {
+		select {
+		case <-signals:
+			if err := r.reload(); err != nil {
+				slog.Error("Error reloading configuration", "error", err)
+				continue
+			}
+			slog.Info("Configuration reloaded")
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *reloader) handleReload(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.reload(); err != nil {
+		slog.Error("Error reloading configuration", "error", err)
+		http.Error(w, "failed to reload configuration: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	slog.Info("Configuration reloaded", "remote_addr", req.RemoteAddr)
+	w.Write([]byte("OK"))
+}
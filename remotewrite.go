@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// remoteWritePusher sends the metrics to a Prometheus remote_write endpoint,
+// e.g. Grafana Cloud or Mimir, with the 1.0 protocol: a snappy compressed
+// protobuf WriteRequest. The few messages involved are encoded by hand
+// rather than pulling in the Prometheus server code for them.
+type remoteWritePusher struct {
+	url         string
+	username    string
+	password    string
+	bearerToken string
+	labels      map[string]string
+	client      *http.Client
+}
+
+func newRemoteWritePusher(config Config) *remoteWritePusher {
+	labels := make(map[string]string)
+	for name, value := range config.RemoteWriteLabels {
+		labels[name] = value
+	}
+	// Prometheus would add job and instance when scraping, without them
+	// the series of several exporters would collide.
+	if labels["job"] == "" {
+		labels["job"] = "outline_exporter"
+	}
+	if labels["instance"] == "" {
+		labels["instance"], _ = os.Hostname()
+	}
+	return &remoteWritePusher{
+		url:         config.RemoteWriteURL,
+		username:    config.RemoteWriteUsername,
+		password:    config.RemoteWritePassword,
+		bearerToken: config.RemoteWriteBearerToken,
+		labels:      labels,
+		client:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (p *remoteWritePusher) name() string {
+	return "remote_write"
+}
+
+func (p *remoteWritePusher) push(ctx context.Context, families []*dto.MetricFamily) error {
+	request := remoteWriteRequest(families, p.labels, time.Now())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(snappy.Encode(nil, request)))
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "outline_exporter/"+version)
+	if p.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+p.bearerToken)
+	} else if p.username != "" {
+		req.SetBasicAuth(p.username, p.password)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+	responseData, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyLength))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, responseData)
+	}
+	return nil
+}
+
+// Field numbers of the remote write protobuf messages.
+const (
+	writeRequestTimeseries = 1
+	writeRequestMetadata   = 3
+	timeSeriesLabels       = 1
+	timeSeriesSamples      = 2
+	labelName              = 1
+	labelValue             = 2
+	sampleValue            = 1
+	sampleTimestamp        = 2
+	metadataType           = 1
+	metadataFamilyName     = 2
+	metadataHelp           = 4
+)
+
+// metadataTypes maps metric types to MetricMetadata.MetricType.
+var metadataTypes = map[dto.MetricType]uint64{
+	dto.MetricType_COUNTER:   1,
+	dto.MetricType_GAUGE:     2,
+	dto.MetricType_HISTOGRAM: 3,
+	dto.MetricType_SUMMARY:   5,
+}
+
+// remoteWriteRequest encodes the families as a WriteRequest, flattening
+// histograms and summaries into their _bucket, _sum and _count series like
+// the text format does.
+func remoteWriteRequest(families []*dto.MetricFamily, external map[string]string, now time.Time) []byte {
+	var request []byte
+	for _, family := range families {
+		name := family.GetName()
+		for _, m := range family.GetMetric() {
+			timestamp := now.UnixMilli()
+			if m.TimestampMs != nil {
+				timestamp = m.GetTimestampMs()
+			}
+			series := func(name string, value float64, extra ...string) {
+				labels := map[string]string{"__name__": name}
+				for n, v := range external {
+					labels[n] = v
+				}
+				for _, label := range m.GetLabel() {
+					labels[label.GetName()] = label.GetValue()
+				}
+				for i := 0; i+1 < len(extra); i += 2 {
+					labels[extra[i]] = extra[i+1]
+				}
+				request = protowire.AppendTag(request, writeRequestTimeseries, protowire.BytesType)
+				request = protowire.AppendBytes(request, timeSeries(labels, value, timestamp))
+			}
+
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				series(name, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				series(name, m.GetGauge().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				histogram := m.GetHistogram()
+				infSeen := false
+				for _, bucket := range histogram.GetBucket() {
+					infSeen = infSeen || math.IsInf(bucket.GetUpperBound(), 1)
+					series(name+"_bucket", float64(bucket.GetCumulativeCount()), "le", formatFloat(bucket.GetUpperBound()))
+				}
+				if !infSeen {
+					series(name+"_bucket", float64(histogram.GetSampleCount()), "le", "+Inf")
+				}
+				series(name+"_sum", histogram.GetSampleSum())
+				series(name+"_count", float64(histogram.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				summary := m.GetSummary()
+				for _, quantile := range summary.GetQuantile() {
+					series(name, quantile.GetValue(), "quantile", formatFloat(quantile.GetQuantile()))
+				}
+				series(name+"_sum", summary.GetSampleSum())
+				series(name+"_count", float64(summary.GetSampleCount()))
+			default:
+				series(name, m.GetUntyped().GetValue())
+			}
+		}
+
+		var metadata []byte
+		metadata = protowire.AppendTag(metadata, metadataType, protowire.VarintType)
+		metadata = protowire.AppendVarint(metadata, metadataTypes[family.GetType()])
+		metadata = protowire.AppendTag(metadata, metadataFamilyName, protowire.BytesType)
+		metadata = protowire.AppendString(metadata, name)
+		metadata = protowire.AppendTag(metadata, metadataHelp, protowire.BytesType)
+		metadata = protowire.AppendString(metadata, family.GetHelp())
+		request = protowire.AppendTag(request, writeRequestMetadata, protowire.BytesType)
+		request = protowire.AppendBytes(request, metadata)
+	}
+	return request
+}
+
+// timeSeries encodes a TimeSeries with a single sample. Receivers require
+// the labels sorted by name, and empty labels are left out as Prometheus
+// does.
+func timeSeries(labels map[string]string, value float64, timestamp int64) []byte {
+	names := make([]string, 0, len(labels))
+	for name, value := range labels {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var encoded []byte
+	for _, name := range names {
+		var label []byte
+		label = protowire.AppendTag(label, labelName, protowire.BytesType)
+		label = protowire.AppendString(label, name)
+		label = protowire.AppendTag(label, labelValue, protowire.BytesType)
+		label = protowire.AppendString(label, labels[name])
+		encoded = protowire.AppendTag(encoded, timeSeriesLabels, protowire.BytesType)
+		encoded = protowire.AppendBytes(encoded, label)
+	}
+
+	var sample []byte
+	sample = protowire.AppendTag(sample, sampleValue, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(value))
+	sample = protowire.AppendTag(sample, sampleTimestamp, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, uint64(timestamp))
+	encoded = protowire.AppendTag(encoded, timeSeriesSamples, protowire.BytesType)
+	return protowire.AppendBytes(encoded, sample)
+}
+
+// formatFloat formats le and quantile label values the way Prometheus does.
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
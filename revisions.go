@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+)
+
+// revisionHistory is what revisions.list returned for a document, kept until
+// the document is updated again.
+type revisionHistory struct {
+	updatedAt time.Time
+	count     int
+	last      Revision
+}
+
+// fetchRevisions refreshes the revision history of documents updated since
+// their history was last listed. Listing every revision takes a request per
+// page per document, so at most REVISION_DOCUMENTS_PER_SCRAPE documents are
+// refreshed per scrape, most recently updated first, and the others are left
+// for the following scrapes.
+func (e *Exporter) fetchRevisions(ctx context.Context, documents []Document) {
+	e.mu.Lock()
+	present := make(map[string]bool, len(documents))
+	var pending []Document
+	for _, document := range documents {
+		if present[document.ID] {
+			continue
+		}
+		present[document.ID] = true
+		if history, ok := e.revisions[document.ID]; !ok || !history.updatedAt.Equal(document.UpdatedAt) {
+			pending = append(pending, document)
+		}
+	}
+	for id := range e.revisions {
+		if !present[id] {
+			delete(e.revisions, id)
+		}
+	}
+	e.mu.Unlock()
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].UpdatedAt.After(pending[j].UpdatedAt)
+	})
+	if limit := e.config.RevisionDocumentsPerScrape; limit > 0 && len(pending) > limit {
+		e.logger.Debug("Deferring revision history", "documents", len(pending)-limit)
+		pending = pending[:limit]
+	}
+
+	var g errgroup.Group
+	g.SetLimit(e.config.RevisionConcurrency)
+	for _, document := range pending {
+		g.Go(func() error {
+			revisions, err := fetchAll[Revision](ctx, e, "/api/revisions.list", map[string]any{
+				"documentId": document.ID,
+				"sort":       "createdAt",
+				"direction":  "DESC",
+			})
+			if err != nil {
+				e.logger.Warn("Error fetching revisions", "document", document.ID, "error", err)
+				return nil
+			}
+			history := revisionHistory{updatedAt: document.UpdatedAt, count: len(revisions)}
+			if len(revisions) > 0 {
+				history.last = revisions[0]
+				if history.last.CreatedAt.After(time.Now()) {
+					e.clockSkewDetections.WithLabelValues("revision").Inc()
+				}
+			}
+			e.mu.Lock()
+			e.revisions[document.ID] = history
+			e.mu.Unlock()
+			return nil
+		})
+	}
+	g.Wait()
+}
+
+// revisionHistoryFor returns the listed revision history of a document.
+func (e *Exporter) revisionHistoryFor(documentID string) (revisionHistory, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	history, ok := e.revisions[documentID]
+	return history, ok
+}
+
+// collectRevisions exports the revision count of a document and, with
+// REVISION_HISTORY, its latest revision.
+func (e *Exporter) collectRevisions(ch chan<- prometheus.Metric, document Document, collectionNames map[string]string) {
+	if e.config.RevisionHistory {
+		if history, ok := e.revisionHistoryFor(document.ID); ok && history.count > 0 {
+			e.collectAge(ch, e.documentLastRevisionAge, e.documentLastRevisionTimestamp,
+				history.last.CreatedAt, e.documentLabelValues(document, collectionNames, history.last.CreatedBy.Name)...)
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(e.documentRevisions, prometheus.GaugeValue,
+		float64(e.revisionCount(document)), e.documentLabelValues(document, collectionNames)...)
+}
+
+// revisionCount returns the number of revisions of a document, taken from its
+// revision history when REVISION_HISTORY is enabled and already listed, and
+// from the revision field of documents.list otherwise.
+func (e *Exporter) revisionCount(document Document) int {
+	if e.config.RevisionHistory {
+		if history, ok := e.revisionHistoryFor(document.ID); ok {
+			return history.count
+		}
+	}
+	return document.Revision
+}
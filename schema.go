@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var schemaFields sync.Map
+
+// expectedFields returns the JSON names of the fields of T that Outline is
+// expected to return. Fields tagged schema:"optional" only exist on some
+// Outline versions and are not checked.
+func expectedFields(t reflect.Type) []string {
+	if fields, ok := schemaFields.Load(t); ok {
+		return fields.([]string)
+	}
+
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" || field.Tag.Get("schema") == "optional" {
+			continue
+		}
+		fields = append(fields, name)
+	}
+	schemaFields.Store(t, fields)
+	return fields
+}
+
+// fetchPage fetches one page of a list endpoint. Besides decoding the items
+// into T, the first item is decoded into a map and compared against the
+// fields T relies on: an Outline upgrade that renames or drops a field would
+// otherwise silently zero the metrics built from it.
+func fetchPage[T any](ctx context.Context, exporter *Exporter, path string, response *apiResp[T], body any) error {
+	ctx, span := tracing.start(ctx, "page", spanKindInternal, "outline.path", path)
+	page := &pageStream[T]{exporter: exporter, path: path}
+	err := exporter.fetch(ctx, path, page, body)
+	span.set("outline.items", len(page.response.Data))
+	span.end(err)
+	if err != nil {
+		return err
+	}
+	*response = page.response
+	if counter := pageCounterFrom(ctx); counter != nil {
+		counter.pages.Add(1)
+		counter.items.Add(int64(len(response.Data)))
+	}
+	// Dropping the text as soon as a page of documents is decoded keeps the
+	// peak memory to a single page of text.
+	if documents, ok := any(response.Data).([]Document); ok && exporter.config.ExcludeDocumentText {
+		dropText(documents)
+	}
+	return nil
+}
+
+// pageCounter counts the pages and items fetched for one resource, however
+// many list endpoints it walks.
+type pageCounter struct {
+	pages atomic.Int64
+	items atomic.Int64
+}
+
+type pageCounterKey struct{}
+
+func withPageCounter(ctx context.Context, counter *pageCounter) context.Context {
+	return context.WithValue(ctx, pageCounterKey{}, counter)
+}
+
+func pageCounterFrom(ctx context.Context) *pageCounter {
+	counter, _ := ctx.Value(pageCounterKey{}).(*pageCounter)
+	return counter
+}
+
+// streamDecoder is implemented by response targets decoding the response
+// while it is read, instead of from a buffer holding all of it.
+type streamDecoder interface {
+	decodeStream(decoder *json.Decoder) error
+}
+
+func decodeResponse(r io.Reader, target any) error {
+	decoder := json.NewDecoder(r)
+	if stream, ok := target.(streamDecoder); ok {
+		return stream.decodeStream(decoder)
+	}
+	return decoder.Decode(target)
+}
+
+// pageStream decodes a page of a list endpoint one item at a time, so a page
+// of large documents is never held in memory both as JSON and decoded.
+type pageStream[T any] struct {
+	exporter *Exporter
+	path     string
+	response apiResp[T]
+}
+
+func (p *pageStream[T]) decodeStream(decoder *json.Decoder) error {
+	if err := expectDelim(decoder, '{'); err != nil {
+		return err
+	}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "data":
+			err = p.decodeData(decoder)
+		case "pagination":
+			err = decoder.Decode(&p.response.Pagination)
+		default:
+			err = decoder.Decode(&json.RawMessage{})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return expectDelim(decoder, '}')
+}
+
+// decodeData decodes the items, either an array or, for item types
+// implementing listKeyed, an object holding the array under their list key.
+func (p *pageStream[T]) decodeData(decoder *json.Decoder) error {
+	token, err := decoder.Token()
+	if err != nil || token == nil {
+		return err
+	}
+	if token == json.Delim('[') {
+		return p.decodeItems(decoder)
+	}
+
+	var zero T
+	keyed, ok := any(zero).(listKeyed)
+	if token != json.Delim('{') || !ok {
+		return fmt.Errorf("decode data: unexpected %v", token)
+	}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if key == keyed.listKey() {
+			if err := expectDelim(decoder, '['); err != nil {
+				return err
+			}
+			err = p.decodeItems(decoder)
+		} else {
+			err = decoder.Decode(&json.RawMessage{})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return expectDelim(decoder, '}')
+}
+
+// decodeItems decodes the items of an array whose opening bracket was read.
+func (p *pageStream[T]) decodeItems(decoder *json.Decoder) error {
+	for decoder.More() {
+		var value T
+		if len(p.response.Data) == 0 {
+			var item json.RawMessage
+			if err := decoder.Decode(&item); err != nil {
+				return fmt.Errorf("decode item: %w", err)
+			}
+			p.exporter.checkSchema(p.path, item, reflect.TypeOf(value))
+			if err := json.Unmarshal(item, &value); err != nil {
+				return fmt.Errorf("decode item: %w", err)
+			}
+		} else if err := decoder.Decode(&value); err != nil {
+			return fmt.Errorf("decode item: %w", err)
+		}
+		p.response.Data = append(p.response.Data, value)
+	}
+	return expectDelim(decoder, ']')
+}
+
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %v, got %v", delim, token)
+	}
+	return nil
+}
+
+// listKeyed is implemented by item types whose list endpoint may wrap the
+// items in an object, next to related data, instead of returning an array.
+type listKeyed interface {
+	listKey() string
+}
+
+func (e *Exporter) checkSchema(path string, item json.RawMessage, t reflect.Type) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(item, &fields); err != nil {
+		return
+	}
+
+	endpoint := endpointOf(path)
+	for _, name := range expectedFields(t) {
+		if _, ok := fields[name]; ok {
+			continue
+		}
+		e.schemaWarnings.WithLabelValues(endpoint, name).Inc()
+
+		e.mu.Lock()
+		key := endpoint + ":" + name
+		first := !e.warned[key]
+		if e.warned == nil {
+			e.warned = make(map[string]bool)
+		}
+		e.warned[key] = true
+		e.mu.Unlock()
+		if first {
+			e.logger.Warn("Response is missing a field, metrics derived from it will be wrong", "endpoint", endpoint, "field", name)
+		}
+	}
+}
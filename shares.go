@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectShares exports share links, so public shares that outlived their
+// purpose can be found. Each share names the document it exposes.
+func (e *Exporter) collectShares(ch chan<- prometheus.Metric, shares []Share) {
+	totals := map[bool]int{true: 0, false: 0}
+	for _, share := range shares {
+		totals[share.Published]++
+		ch <- prometheus.MustNewConstMetric(e.sharePublished, prometheus.GaugeValue,
+			boolValue(share.Published), share.ID, share.DocumentID)
+		e.collectAge(ch, e.shareAge, e.shareCreatedTimestamp,
+			share.CreatedAt, share.ID, share.DocumentID)
+	}
+	for published, count := range totals {
+		ch <- prometheus.MustNewConstMetric(e.sharesTotal, prometheus.GaugeValue,
+			float64(count), strconv.FormatBool(published))
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"time"
+	"unsafe"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// snapshot holds everything fetched from Outline during one scrape. The
+// previous snapshot is kept on the exporter so metrics describing changes
+// between scrapes can be derived from it.
+type snapshot struct {
+	team           *authInfo
+	collections    []Collection
+	memberships    []membershipCounts
+	documents      []Document
+	drafts         []Document
+	archived       []Document
+	deleted        []Document
+	templates      []Document
+	users          []User
+	userStates     *userStates
+	comments       []Comment
+	groups         []Group
+	shares         []Share
+	stars          []Star
+	fileOperations []FileOperation
+	searchResults  map[string]int
+	failed         map[string]bool
+	skipped        map[string]bool
+	// resources lists the resources the scrape tried to fetch.
+	resources []string
+	takenAt   time.Time
+
+	generation      uint64
+	refreshDuration time.Duration
+	// stageDurations is how long fetching each resource took.
+	stageDurations map[string]time.Duration
+	// pagesFetched and itemsFetched count what the list endpoints returned
+	// for each resource, before any filtering.
+	pagesFetched map[string]int
+	itemsFetched map[string]int
+	// pending marks the placeholder served when a scrape gave up waiting for
+	// the first background refresh: nothing was fetched yet.
+	pending bool
+}
+
+func (s *snapshot) ok() bool {
+	return !s.pending && len(s.failed) == 0
+}
+
+// fetched reports whether the resource was actually retrieved, as opposed to
+// failing or being skipped because its collector is disabled.
+func (s *snapshot) fetched(resource string) bool {
+	return !s.pending && !s.failed[resource] && !s.skipped[resource]
+}
+
+func (s *snapshot) items() map[string]int {
+	return map[string]int{
+		"collections":     len(s.collections),
+		"documents":       len(s.documents),
+		"drafts":          len(s.drafts),
+		"archived":        len(s.archived),
+		"deleted":         len(s.deleted),
+		"templates":       len(s.templates),
+		"users":           len(s.users),
+		"comments":        len(s.comments),
+		"groups":          len(s.groups),
+		"shares":          len(s.shares),
+		"stars":           len(s.stars),
+		"file_operations": len(s.fileOperations),
+		"search_results":  len(s.searchResults),
+	}
+}
+
+// bytes estimates the memory held by the snapshot: the fixed size of every
+// struct plus the variable length of its strings. Document text dominates on
+// most wikis.
+func (s *snapshot) bytes() int {
+	size := int(unsafe.Sizeof(*s))
+	for _, collection := range s.collections {
+		size += int(unsafe.Sizeof(collection)) + len(collection.ID) + len(collection.Name) + len(collection.Description)
+	}
+	for _, document := range s.documents {
+		size += int(unsafe.Sizeof(document)) + len(document.ID) + len(document.Title) + len(document.Text) + len(document.CollectionId)
+	}
+	for _, documents := range [][]Document{s.drafts, s.archived, s.deleted, s.templates} {
+		for _, document := range documents {
+			size += int(unsafe.Sizeof(document)) + len(document.ID) + len(document.Title) + len(document.Text) + len(document.CollectionId)
+		}
+	}
+	for _, user := range s.users {
+		size += int(unsafe.Sizeof(user)) + len(user.ID) + len(user.Name)
+	}
+	for _, comment := range s.comments {
+		size += int(unsafe.Sizeof(comment)) + len(comment.ID) + len(comment.DocumentID) + len(comment.ParentCommentID)
+	}
+	for _, group := range s.groups {
+		size += int(unsafe.Sizeof(group)) + len(group.ID) + len(group.Name)
+	}
+	for _, share := range s.shares {
+		size += int(unsafe.Sizeof(share)) + len(share.ID) + len(share.DocumentID)
+	}
+	for _, star := range s.stars {
+		size += int(unsafe.Sizeof(star)) + len(star.ID) + len(star.DocumentID) + len(star.CollectionID)
+	}
+	for _, operation := range s.fileOperations {
+		size += int(unsafe.Sizeof(operation)) + len(operation.ID) + len(operation.Type) + len(operation.State)
+	}
+	for name := range s.searchResults {
+		size += len(name) + int(unsafe.Sizeof(0))
+	}
+	return size
+}
+
+func (s *snapshot) collectionNames() map[string]string {
+	names := make(map[string]string, len(s.collections))
+	for _, collection := range s.collections {
+		names[collection.ID] = collection.Name
+	}
+	return names
+}
+
+func (s *snapshot) collectionDocuments() map[string]map[string]bool {
+	index := make(map[string]map[string]bool)
+	for _, document := range s.documents {
+		if index[document.CollectionId] == nil {
+			index[document.CollectionId] = make(map[string]bool)
+		}
+		index[document.CollectionId][document.ID] = true
+	}
+	return index
+}
+
+// invalidate drops the retained snapshot so the next scrape starts from a
+// clean baseline, e.g. after Outline was restored from a backup. The cached
+// snapshot is dropped as well, or rebuilt right away when refreshing in the
+// background.
+func (e *Exporter) invalidate() {
+	// The inventory and the event cursor are locked for a whole scrape, so
+	// they are reset before taking e.mu.
+	e.inventory.mu.Lock()
+	e.inventory.documents = nil
+	e.inventory.since = time.Time{}
+	e.inventory.mu.Unlock()
+
+	e.events.mu.Lock()
+	e.events.started = false
+	e.events.last = Event{}
+	e.events.mu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.previous = nil
+	e.lastViewed = nil
+	e.documentViewsRate = nil
+	e.collectionViewsRate = nil
+	e.revisions = make(map[string]revisionHistory)
+	e.attachmentSizes = make(map[string]int64)
+	if e.config.ScrapeInterval > 0 {
+		// /metrics keeps serving the latest snapshot in the background mode,
+		// so rebuild it now instead of at the next interval.
+		go e.refresh(e.ctx)
+	} else {
+		e.latest = nil
+	}
+}
+
+func (e *Exporter) collectCacheSize(ch chan<- prometheus.Metric) {
+	e.mu.Lock()
+	retained := e.previous
+	e.mu.Unlock()
+
+	if retained == nil {
+		retained = &snapshot{}
+	}
+	for itemType, count := range retained.items() {
+		ch <- prometheus.MustNewConstMetric(e.cacheItems, prometheus.GaugeValue, float64(count), itemType)
+	}
+	ch <- prometheus.MustNewConstMetric(e.cacheBytes, prometheus.GaugeValue, float64(retained.bytes()))
+}
+
+// baseline returns the snapshot the next scrape is compared against.
+// Resources that failed to fetch or were skipped this time are carried over from the
+// previous baseline so that an API error doesn't look like every entity
+// vanishing and then reappearing.
+func (s *snapshot) baseline(previous *snapshot) *snapshot {
+	if previous == nil {
+		return s
+	}
+
+	b := *s
+	b.failed = make(map[string]bool)
+	b.skipped = nil
+	if !s.fetched("collections") {
+		b.collections = previous.collections
+		b.failed["collections"] = !previous.fetched("collections")
+	}
+	if !s.fetched("documents") {
+		// The view rate is computed from the time elapsed since the
+		// documents were fetched, so their timestamp travels with them.
+		b.documents = previous.documents
+		b.takenAt = previous.takenAt
+		b.failed["documents"] = !previous.fetched("documents")
+	}
+	if !s.fetched("users") {
+		b.users = previous.users
+		b.failed["users"] = !previous.fetched("users")
+	}
+	if !s.fetched("comments") {
+		b.comments = previous.comments
+		b.failed["comments"] = !previous.fetched("comments")
+	}
+	return &b
+}
+
+// trackChanges compares the snapshot with the previous baseline. It counts
+// documents added to and removed from each collection, counts deleted
+// entities, and drops counter series of collections that no longer exist or
+// were renamed so they don't linger forever. The first snapshot only
+// establishes a baseline.
+func (e *Exporter) trackChanges(current *snapshot) {
+	e.mu.Lock()
+	previous := e.previous
+	e.previous = current.baseline(previous)
+	if previous != nil && previous.fetched("documents") && current.fetched("documents") {
+		e.trackViews(previous, current)
+	}
+	e.mu.Unlock()
+
+	if previous == nil {
+		return
+	}
+	comparable := func(resource string) bool {
+		return previous.fetched(resource) && current.fetched(resource)
+	}
+
+	previousNames := previous.collectionNames()
+	names := current.collectionNames()
+	for id, name := range previousNames {
+		if _, ok := names[id]; !ok {
+			names[id] = name
+		}
+	}
+
+	if comparable("documents") {
+		before := previous.collectionDocuments()
+		after := current.collectionDocuments()
+		for collectionID, documentIDs := range after {
+			for documentID := range documentIDs {
+				if !before[collectionID][documentID] {
+					e.collectionDocumentsAdded.WithLabelValues(collectionID, names[collectionID]).Inc()
+				}
+			}
+		}
+		for collectionID, documentIDs := range before {
+			for documentID := range documentIDs {
+				if !after[collectionID][documentID] {
+					e.collectionDocumentsRemoved.WithLabelValues(collectionID, names[collectionID]).Inc()
+				}
+			}
+		}
+
+		e.countDeleted("document", previous.documentIDs(), current.documentIDs())
+	}
+
+	if comparable("users") {
+		e.countDeleted("user", previous.userIDs(), current.userIDs())
+	}
+
+	if comparable("comments") {
+		e.countNewComments(previous.commentIDs(), current.commentIDs())
+	}
+
+	if comparable("collections") {
+		currentNames := current.collectionNames()
+		for id, name := range previousNames {
+			if currentName, ok := currentNames[id]; !ok || currentName != name {
+				e.collectionDocumentsAdded.DeleteLabelValues(id, name)
+				e.collectionDocumentsRemoved.DeleteLabelValues(id, name)
+			}
+		}
+		previousIDs := make(map[string]bool, len(previousNames))
+		for id := range previousNames {
+			previousIDs[id] = true
+		}
+		currentIDs := make(map[string]bool, len(currentNames))
+		for id := range currentNames {
+			currentIDs[id] = true
+		}
+		e.countDeleted("collection", previousIDs, currentIDs)
+	}
+}
+
+func (e *Exporter) countDeleted(entity string, before, after map[string]bool) {
+	deleted := 0
+	for id := range before {
+		if !after[id] {
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		e.logger.Debug("Entities deleted since last scrape", "entity", entity, "count", deleted)
+		e.entitiesDeleted.WithLabelValues(entity).Add(float64(deleted))
+	}
+}
+
+func (s *snapshot) documentIDs() map[string]bool {
+	ids := make(map[string]bool, len(s.documents))
+	for _, document := range s.documents {
+		ids[document.ID] = true
+	}
+	return ids
+}
+
+func (s *snapshot) userIDs() map[string]bool {
+	ids := make(map[string]bool, len(s.users))
+	for _, user := range s.users {
+		ids[user.ID] = true
+	}
+	return ids
+}
@@ -0,0 +1,30 @@
+package main
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// listKey implements listKeyed: recent Outline versions return the stars of
+// stars.list together with the starred documents in an object.
+func (Star) listKey() string {
+	return "stars"
+}
+
+// collectStars exports the number of stars per document, for the documents
+// DOCUMENT_TOP_N and DOCUMENT_ACTIVITY_WINDOW keep. Collections can be
+// starred too; those stars only count towards the total.
+func (e *Exporter) collectStars(ch chan<- prometheus.Metric, snap *snapshot) {
+	ch <- prometheus.MustNewConstMetric(e.starsTotal, prometheus.GaugeValue, float64(len(snap.stars)))
+	if !e.config.PerDocumentMetrics {
+		return
+	}
+
+	detailed := e.detailedDocumentIDs(snap.documents)
+	counts := make(map[string]int)
+	for _, star := range snap.stars {
+		if detailed[star.DocumentID] {
+			counts[star.DocumentID]++
+		}
+	}
+	for documentID, count := range counts {
+		ch <- prometheus.MustNewConstMetric(e.documentStars, prometheus.GaugeValue, float64(count), documentID)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchStarsWrappedInObject(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"data": {
+				"stars": [
+					{"id": "s1", "documentId": "d1", "createdAt": "2024-01-01T00:00:00Z"},
+					{"id": "s2", "collectionId": "c1", "createdAt": "2024-01-02T00:00:00Z"}
+				],
+				"documents": [{"id": "d1", "title": "Starred"}]
+			},
+			"pagination": {"limit": 100, "offset": 0}
+		}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("OUTLINE_API_URL", server.URL)
+	t.Setenv("OUTLINE_API_KEY", "test")
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stars, err := fetchAll[Star](context.Background(), newExporter(config), "/api/stars.list", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stars) != 2 || stars[0].DocumentID != "d1" || stars[1].CollectionID != "c1" {
+		t.Errorf("stars = %+v, want s1 on d1 and s2 on c1", stars)
+	}
+}
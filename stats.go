@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+type instanceStats struct {
+	Instance    string            `json:"instance,omitempty"`
+	TakenAt     *time.Time        `json:"taken_at,omitempty"`
+	Generation  uint64            `json:"generation"`
+	Up          bool              `json:"up"`
+	Collections []collectionStats `json:"collections,omitempty"`
+	Documents   *documentStats    `json:"documents,omitempty"`
+	Users       *userStats        `json:"users,omitempty"`
+}
+
+type collectionStats struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Private   bool      `json:"private"`
+	Documents int       `json:"documents"`
+	Views     int       `json:"views"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type documentStats struct {
+	Total     int        `json:"total"`
+	Views     int        `json:"views"`
+	Drafts    *int       `json:"drafts,omitempty"`
+	Archived  *int       `json:"archived,omitempty"`
+	Deleted   *int       `json:"deleted,omitempty"`
+	Templates *int       `json:"templates,omitempty"`
+	UpdatedAt *time.Time `json:"last_updated_at,omitempty"`
+}
+
+type userStats struct {
+	Total     int            `json:"total"`
+	ByRole    map[string]int `json:"by_role"`
+	Active    map[string]int `json:"active"`
+	Suspended *int           `json:"suspended,omitempty"`
+	Invited   *int           `json:"invited,omitempty"`
+}
+
+// newInstanceStats summarizes a snapshot the way the metrics do. Resources
+// that weren't fetched are left out rather than reported as empty.
+func (e *Exporter) newInstanceStats(snap *snapshot) instanceStats {
+	stats := instanceStats{Instance: e.config.InstanceName}
+	if snap == nil {
+		return stats
+	}
+	stats.TakenAt = &snap.takenAt
+	stats.Generation = snap.generation
+	stats.Up = snap.ok()
+
+	count := func(resource string, items int) *int {
+		if !snap.fetched(resource) {
+			return nil
+		}
+		return &items
+	}
+
+	// Duplicate documents are skipped like on /metrics, for the totals and
+	// the collections alike.
+	var unique []Document
+	seen := make(map[string]bool)
+	for _, document := range snap.documents {
+		key := document.ID + ":" + document.CollectionId
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, document)
+		}
+	}
+
+	if snap.fetched("documents") {
+		documents := &documentStats{
+			Drafts:    count("drafts", len(snap.drafts)),
+			Archived:  count("archived", len(snap.archived)),
+			Deleted:   count("deleted", len(snap.deleted)),
+			Templates: count("templates", len(snap.templates)),
+		}
+		for _, document := range unique {
+			documents.Total++
+			documents.Views += document.Views
+			if documents.UpdatedAt == nil || document.UpdatedAt.After(*documents.UpdatedAt) {
+				updatedAt := document.UpdatedAt
+				documents.UpdatedAt = &updatedAt
+			}
+		}
+		stats.Documents = documents
+	}
+
+	if snap.fetched("collections") {
+		documentCounts := make(map[string]int)
+		views := make(map[string]int)
+		for _, document := range unique {
+			documentCounts[document.CollectionId]++
+			views[document.CollectionId] += document.Views
+		}
+		stats.Collections = []collectionStats{}
+		for _, collection := range snap.collections {
+			stats.Collections = append(stats.Collections, collectionStats{
+				ID:        collection.ID,
+				Name:      collection.Name,
+				Private:   collection.private(),
+				Documents: documentCounts[collection.ID],
+				Views:     views[collection.ID],
+				UpdatedAt: collection.UpdatedAt,
+			})
+		}
+		sort.Slice(stats.Collections, func(i, j int) bool { return stats.Collections[i].Name < stats.Collections[j].Name })
+	}
+
+	if snap.fetched("users") {
+		users := &userStats{Total: len(snap.users), ByRole: make(map[string]int), Active: make(map[string]int)}
+		for _, role := range userRoles {
+			users.ByRole[role] = 0
+		}
+		for _, user := range snap.users {
+			users.ByRole[user.role()]++
+		}
+		// Active users are counted over the USER_ACTIVITY_BUCKETS windows,
+		// e.g. "7d" for those seen in the last week.
+		for _, window := range e.config.UserActivityBuckets {
+			active := 0
+			for _, user := range snap.users {
+				if !user.LastActiveAt.IsZero() && user.LastActiveAt.After(e.cutoff(window)) {
+					active++
+				}
+			}
+			users.Active[formatDuration(window)] = active
+		}
+		if snap.userStates != nil {
+			users.Suspended = count("user_states", len(snap.userStates.suspended))
+			users.Invited = count("user_states", len(snap.userStates.invited))
+		}
+		stats.Users = users
+	}
+	return stats
+}
+
+// handleStats serves the latest data collected from every instance, for
+// tools that don't read Prometheus. It never triggers a scrape.
+func handleStats(exporters func() []*Exporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list := []instanceStats{}
+		for _, exporter := range exporters() {
+			list = append(list, exporter.newInstanceStats(exporter.latestSnapshot()))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type resourceStatus struct {
+	Up            bool       `json:"up"`
+	LastSuccess   *time.Time `json:"last_success,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_time,omitempty"`
+	ErrorClass    string     `json:"error_class,omitempty"`
+}
+
+type instanceStatus struct {
+	Instance   string                    `json:"instance,omitempty"`
+	Up         bool                      `json:"up"`
+	LastScrape *time.Time                `json:"last_scrape,omitempty"`
+	Generation uint64                    `json:"generation"`
+	Resources  map[string]resourceStatus `json:"resources"`
+}
+
+// statusLog keeps the outcome of the latest fetch of each resource together
+// with the last error it failed with, so the reason outline_up is 0 can be
+// looked up without the container logs.
+type statusLog struct {
+	mu        sync.Mutex
+	resources map[string]resourceStatus
+}
+
+func newStatusLog() *statusLog {
+	return &statusLog{resources: make(map[string]resourceStatus)}
+}
+
+func (l *statusLog) record(resource string, err error) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	status := l.resources[resource]
+	status.Up = err == nil
+	if err == nil {
+		status.LastSuccess = &now
+	} else {
+		status.LastError = statusMessage(err)
+		status.LastErrorTime = &now
+		status.ErrorClass = errorClass(err)
+	}
+	l.resources[resource] = status
+}
+
+func (l *statusLog) list() map[string]resourceStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	list := make(map[string]resourceStatus, len(l.resources))
+	for resource, status := range l.resources {
+		list[resource] = status
+	}
+	return list
+}
+
+// statusMessage describes an error without the response body, which /errors
+// keeps behind the admin token.
+func statusMessage(err error) string {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return fmt.Sprintf("status %d: %s", apiErr.StatusCode, http.StatusText(apiErr.StatusCode))
+	}
+	return truncate(err.Error(), maxErrorBodyLength)
+}
+
+// handleStatus serves the state of the latest scrape of every instance.
+func handleStatus(exporters func() []*Exporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list := []instanceStatus{}
+		for _, exporter := range exporters() {
+			status := instanceStatus{Instance: exporter.config.InstanceName, Resources: exporter.statuses.list()}
+			if snap := exporter.latestSnapshot(); snap != nil {
+				status.Up = snap.ok()
+				status.LastScrape = &snap.takenAt
+				status.Generation = snap.generation
+			}
+			list = append(list, status)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// authInfo is the workspace and user the API key belongs to.
+type authInfo struct {
+	User UserRef `json:"user"`
+	Team struct {
+		ID        string `json:"id"`
+		Name      string `json:"name"`
+		Subdomain string `json:"subdomain"`
+		Sharing   bool   `json:"sharing"`
+	} `json:"team"`
+}
+
+func (e *Exporter) fetchAuthInfo(ctx context.Context) (*authInfo, error) {
+	var response struct {
+		Data authInfo `json:"data"`
+	}
+	if err := e.fetch(ctx, "/api/auth.info", &response, map[string]any{}); err != nil {
+		return nil, err
+	}
+	return &response.Data, nil
+}
+
+func (e *Exporter) collectTeam(ch chan<- prometheus.Metric, info *authInfo) {
+	ch <- prometheus.MustNewConstMetric(e.teamInfo, prometheus.GaugeValue, 1,
+		info.Team.ID, info.Team.Name, info.Team.Subdomain, strconv.FormatBool(info.Team.Sharing), info.User.ID)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fetchTemplates lists template documents. Recent versions of Outline keep
+// templates apart and serve them from templates.list; older ones flag them
+// on documents, and documents.list filters on the flag. Versions ignoring
+// the filter return every document, so the flag is checked as well.
+func (e *Exporter) fetchTemplates(ctx context.Context) ([]Document, error) {
+	templates, err := fetchAll[Document](withOptionalEndpoint(ctx), e, "/api/templates.list", nil)
+	var apiErr *apiError
+	if err == nil {
+		for i := range templates {
+			templates[i].Template = true
+		}
+		return e.filterDocuments(templates), nil
+	}
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		return nil, err
+	}
+
+	documents, err := fetchAll[Document](ctx, e, "/api/documents.list", map[string]any{"template": true})
+	if err != nil {
+		return nil, err
+	}
+	for _, document := range e.filterDocuments(documents) {
+		if document.Template {
+			templates = append(templates, document)
+		}
+	}
+	return templates, nil
+}
+
+type optionalEndpointKey struct{}
+
+// withOptionalEndpoint marks the requests made with ctx as going to an
+// endpoint older versions of Outline don't serve, so a 404 falls back
+// silently instead of being recorded as an API error on every scrape.
+func withOptionalEndpoint(ctx context.Context) context.Context {
+	return context.WithValue(ctx, optionalEndpointKey{}, true)
+}
+
+// expectedNotFound reports whether err is the 404 of an optional endpoint.
+func expectedNotFound(ctx context.Context, err error) bool {
+	var apiErr *apiError
+	optional, _ := ctx.Value(optionalEndpointKey{}).(bool)
+	return optional && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// collectTemplates exports templates per collection and how long ago each
+// one was last updated, so outdated onboarding templates can be found.
+func (e *Exporter) collectTemplates(ch chan<- prometheus.Metric, snap *snapshot) {
+	ch <- prometheus.MustNewConstMetric(e.templatesTotal, prometheus.GaugeValue, float64(len(snap.templates)))
+
+	counts := make(map[string]int)
+	for _, template := range snap.templates {
+		counts[template.CollectionId]++
+		e.collectAge(ch, e.templateUpdateAge, e.templateUpdatedTimestamp,
+			template.UpdatedAt, template.ID, template.CollectionId)
+	}
+	collectionNames := snap.collectionNames()
+	for collectionID, count := range counts {
+		ch <- prometheus.MustNewConstMetric(e.collectionTemplates, prometheus.GaugeValue,
+			float64(count), collectionID, collectionNames[collectionID])
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"strconv"
+)
+
+// getOutlineTLS builds the TLS configuration used when calling Outline:
+// the OUTLINE_TLS_CERT client certificate for proxies requiring mutual TLS,
+// the OUTLINE_CA_FILE bundle for internal CAs and OUTLINE_TLS_INSECURE for
+// self-signed certificates. It returns nil when nothing needs to change from
+// Go's defaults.
+func getOutlineTLS() *tls.Config {
+	certFile, keyFile := getEnv("OUTLINE_TLS_CERT", ""), getEnv("OUTLINE_TLS_KEY", "")
+	caFile := getEnv("OUTLINE_CA_FILE", "")
+	insecure := getBool("OUTLINE_TLS_INSECURE", false)
+	if certFile == "" && keyFile == "" && caFile == "" && !insecure {
+		return nil
+	}
+
+	config := &tls.Config{InsecureSkipVerify: insecure}
+	if insecure {
+		slog.Warn("OUTLINE_TLS_INSECURE is set, the Outline certificate is not verified")
+	}
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			configError("OUTLINE_TLS_CERT and OUTLINE_TLS_KEY must be set together")
+		} else if certificate, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+			configError("loading Outline client certificate: %v", err)
+		} else {
+			config.Certificates = []tls.Certificate{certificate}
+			slog.Info("Using client certificate for the Outline API", "certificate", certFile)
+		}
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			configError("reading OUTLINE_CA_FILE: %v", err)
+			return config
+		}
+		// The bundle extends the system roots so public certificates, e.g.
+		// of OUTLINE_METRICS_URL, keep working.
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			configError("OUTLINE_CA_FILE %s contains no PEM certificates", caFile)
+		}
+		config.RootCAs = pool
+	}
+	return config
+}
+
+// newTransport returns the HTTP transport for requests to Outline. The
+// resources of a scrape are fetched concurrently, so more idle connections
+// per host are kept than the Go default of 2. Unless disabled, the transport
+// asks for gzip responses and decompresses them transparently; document
+// listings compress about tenfold.
+func newTransport(config Config) http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config.OutlineTLS
+	transport.MaxIdleConns = config.HTTPMaxIdleConns
+	transport.MaxIdleConnsPerHost = config.HTTPMaxIdleConnsPerHost
+	transport.IdleConnTimeout = config.HTTPIdleConnTimeout
+	transport.DisableKeepAlives = !config.HTTPKeepAlives
+	transport.DisableCompression = !config.HTTPCompression
+	return transport
+}
+
+// traceConnections counts whether requests made with the returned context
+// reused an idle connection.
+func (e *Exporter) traceConnections(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			e.apiConnections.WithLabelValues(strconv.FormatBool(info.Reused)).Inc()
+		},
+	})
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxPendingSpans bounds the spans buffered between two exports, so an
+// unreachable collector can't grow the memory without limit.
+const maxPendingSpans = 4096
+
+// traceExportInterval is how often the finished spans are sent.
+const traceExportInterval = 5 * time.Second
+
+// Span kinds and status codes of OTLP.
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+	spanStatusError  = 2
+)
+
+// tracing is nil unless OTEL_TRACES_EXPORTER=otlp, in which case it records
+// spans of the scrapes down to each HTTP request sent to Outline.
+var tracing *tracer
+
+// tracer sends the spans of the exporter to an OpenTelemetry collector with
+// OTLP over HTTP. A nil tracer records nothing, so the instrumented code
+// doesn't check whether tracing is enabled.
+type tracer struct {
+	url      string
+	headers  map[string]string
+	resource otlpResource
+	client   *http.Client
+
+	mu      sync.Mutex
+	pending []otlpSpan
+	dropped int
+}
+
+func newTracer(config Config) *tracer {
+	if config.TracesEndpoint == "" {
+		return nil
+	}
+	return &tracer{
+		url:      config.TracesEndpoint,
+		headers:  config.OTLPHeaders,
+		resource: newOTLPResource(config),
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type spanKey struct{}
+
+// span is an operation being traced. Its methods do nothing on a nil span.
+type span struct {
+	tracer     *tracer
+	traceID    [16]byte
+	spanID     [8]byte
+	parentID   [8]byte
+	name       string
+	kind       int
+	start      time.Time
+	attributes map[string]string
+}
+
+// start begins a span, a child of the span in ctx if there is one, and
+// returns a context carrying it. attributes are key, value pairs.
+func (t *tracer) start(ctx context.Context, name string, kind int, attributes ...any) (context.Context, *span) {
+	if t == nil {
+		return ctx, nil
+	}
+	s := &span{tracer: t, name: name, kind: kind, start: time.Now(), attributes: make(map[string]string)}
+	if parent := spanFrom(ctx); parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.spanID[:])
+	s.set(attributes...)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// spanFrom returns the span carried by ctx, nil when there is none.
+func spanFrom(ctx context.Context) *span {
+	s, _ := ctx.Value(spanKey{}).(*span)
+	return s
+}
+
+func (s *span) set(attributes ...any) {
+	if s == nil {
+		return
+	}
+	for i := 0; i+1 < len(attributes); i += 2 {
+		s.attributes[fmt.Sprint(attributes[i])] = fmt.Sprint(attributes[i+1])
+	}
+}
+
+// traceparent is the W3C trace context header of the span, sent to Outline
+// so its own traces can be joined with the exporter's.
+func (s *span) traceparent() string {
+	return "00-" + hex.EncodeToString(s.traceID[:]) + "-" + hex.EncodeToString(s.spanID[:]) + "-01"
+}
+
+// end finishes the span, marking it failed when err isn't nil.
+func (s *span) end(err error) {
+	if s == nil {
+		return
+	}
+	recorded := otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: otlpTime(s.start),
+		EndTimeUnixNano:   otlpTime(time.Now()),
+		Attributes:        otlpAttributes(s.attributes),
+	}
+	if s.parentID != [8]byte{} {
+		recorded.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	if err != nil {
+		recorded.Status = &otlpStatus{Code: spanStatusError, Message: err.Error()}
+	}
+
+	t := s.tracer
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.pending) >= maxPendingSpans {
+		t.dropped++
+		return
+	}
+	t.pending = append(t.pending, recorded)
+}
+
+// run exports the finished spans every traceExportInterval until ctx is
+// cancelled.
+func (t *tracer) run(ctx context.Context) {
+	ticker := time.NewTicker(traceExportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.export(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (t *tracer) export(ctx context.Context) {
+	t.mu.Lock()
+	spans, dropped := t.pending, t.dropped
+	t.pending, t.dropped = nil, 0
+	t.mu.Unlock()
+
+	if dropped > 0 {
+		slog.Warn("Dropped spans, the collector isn't keeping up", "spans", dropped)
+	}
+	if len(spans) == 0 {
+		return
+	}
+	request := otlpTracesRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: t.resource,
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "outline_exporter", Version: version},
+			Spans: spans,
+		}},
+	}}}
+	if err := postOTLP(ctx, t.client, t.url, t.headers, request); err != nil {
+		pushErrors.WithLabelValues("otlp_traces").Inc()
+		slog.Error("Error exporting spans", "spans", len(spans), "error", err)
+	}
+}
+
+type otlpTracesRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+// Trace and span IDs are hex encoded in the JSON encoding of OTLP.
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
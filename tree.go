@@ -0,0 +1,73 @@
+package main
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// documentDepths returns the nesting depth of every document, 1 for the
+// documents at the root of a collection. A document whose parent wasn't
+// fetched, e.g. because it is archived, counts as a root.
+func documentDepths(documents []Document) map[string]int {
+	parents := make(map[string]string, len(documents))
+	for _, document := range documents {
+		parents[document.ID] = document.ParentDocumentID
+	}
+
+	depths := make(map[string]int, len(documents))
+	var depth func(id string, seen map[string]bool) int
+	depth = func(id string, seen map[string]bool) int {
+		if d, ok := depths[id]; ok {
+			return d
+		}
+		d := 1
+		parentID := parents[id]
+		_, fetched := parents[parentID]
+		// Guard against cycles, which a move racing the scrape can produce.
+		if parentID != "" && fetched && !seen[id] {
+			seen[id] = true
+			d = depth(parentID, seen) + 1
+		}
+		depths[id] = d
+		return d
+	}
+	for id := range parents {
+		depth(id, make(map[string]bool))
+	}
+	return depths
+}
+
+// collectNesting exports the shape of each collection's document tree.
+func (e *Exporter) collectNesting(ch chan<- prometheus.Metric, documents []Document, collectionNames map[string]string) {
+	depths := documentDepths(documents)
+	type tree struct {
+		roots, documents, maxDepth, totalDepth int
+	}
+	trees := make(map[string]*tree)
+	seen := make(map[string]bool, len(documents))
+	for _, document := range documents {
+		if seen[document.ID] {
+			continue
+		}
+		seen[document.ID] = true
+		t := trees[document.CollectionId]
+		if t == nil {
+			t = &tree{}
+			trees[document.CollectionId] = t
+		}
+		d := depths[document.ID]
+		if d == 1 {
+			t.roots++
+		}
+		t.documents++
+		t.totalDepth += d
+		t.maxDepth = max(t.maxDepth, d)
+	}
+
+	for collectionID, t := range trees {
+		name := collectionNames[collectionID]
+		ch <- prometheus.MustNewConstMetric(e.collectionRootDocuments, prometheus.GaugeValue,
+			float64(t.roots), collectionID, name)
+		ch <- prometheus.MustNewConstMetric(e.collectionMaxDepth, prometheus.GaugeValue,
+			float64(t.maxDepth), collectionID, name)
+		ch <- prometheus.MustNewConstMetric(e.collectionAvgDepth, prometheus.GaugeValue,
+			float64(t.totalDepth)/float64(t.documents), collectionID, name)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectUserActivityHistogram exports how long ago users were last active
+// as a single histogram, which gives engagement curves without one series
+// per user. Users who were never active are left out.
+func (e *Exporter) collectUserActivityHistogram(ch chan<- prometheus.Metric, users []User) {
+	h := newHistogram(secondsBuckets(e.config.UserActivityBuckets))
+	for _, user := range users {
+		if !user.LastActiveAt.IsZero() {
+			h.observe(e.age(user.LastActiveAt))
+		}
+	}
+	ch <- prometheus.MustNewConstHistogram(e.usersLastActive, h.count, h.sum, h.buckets)
+}
+
+var userRoles = []string{"admin", "member", "viewer", "guest"}
+
+// role returns the role of the user. Older versions of Outline only report
+// the isAdmin and isViewer flags.
+func (u User) role() string {
+	switch {
+	case u.Role != "":
+		return u.Role
+	case u.IsAdmin:
+		return "admin"
+	case u.IsViewer:
+		return "viewer"
+	default:
+		return "member"
+	}
+}
+
+// collectUserRoles exports the number of users per role. Every role is
+// exported, so an alert on the admin count doesn't go absent when it drops
+// to zero.
+func (e *Exporter) collectUserRoles(ch chan<- prometheus.Metric, users []User) {
+	counts := make(map[string]int, len(userRoles))
+	for _, role := range userRoles {
+		counts[role] = 0
+	}
+	for _, user := range users {
+		counts[user.role()]++
+	}
+	for role, count := range counts {
+		ch <- prometheus.MustNewConstMetric(e.usersByRole, prometheus.GaugeValue, float64(count), role)
+	}
+}
+
+// userStates holds the users listed with the suspended and invited filters
+// of users.list, which the unfiltered list leaves out or mixes in depending
+// on the Outline version.
+type userStates struct {
+	suspended []User
+	invited   []User
+}
+
+// fetchUserStates lists suspended users and pending invitations. Versions of
+// Outline ignoring the filter return every user, so the state of each one is
+// checked as well: invited users are those who never signed in.
+func (e *Exporter) fetchUserStates(ctx context.Context) (*userStates, error) {
+	states := &userStates{}
+	suspended, err := fetchAll[User](ctx, e, "/api/users.list", map[string]any{"filter": "suspended"})
+	if err != nil {
+		return nil, fmt.Errorf("list suspended users: %w", err)
+	}
+	for _, user := range suspended {
+		if user.IsSuspended {
+			states.suspended = append(states.suspended, user)
+		}
+	}
+
+	invited, err := fetchAll[User](ctx, e, "/api/users.list", map[string]any{"filter": "invited"})
+	if err != nil {
+		return nil, fmt.Errorf("list invited users: %w", err)
+	}
+	for _, user := range invited {
+		if user.LastActiveAt.IsZero() && !user.IsSuspended {
+			states.invited = append(states.invited, user)
+		}
+	}
+	return states, nil
+}
+
+func (e *Exporter) collectUserStates(ch chan<- prometheus.Metric, states *userStates) {
+	ch <- prometheus.MustNewConstMetric(e.usersSuspended, prometheus.GaugeValue, float64(len(states.suspended)))
+	ch <- prometheus.MustNewConstMetric(e.usersInvited, prometheus.GaugeValue, float64(len(states.invited)))
+
+	var oldest float64
+	for _, user := range states.invited {
+		oldest = max(oldest, e.age(user.CreatedAt))
+	}
+	ch <- prometheus.MustNewConstMetric(e.oldestInviteAge, prometheus.GaugeValue, oldest)
+}
+
+// collectCreators exports how many documents each user created, to show how
+// contributions are spread across the team. Documents that don't report
+// their creator are left out.
+func (e *Exporter) collectCreators(ch chan<- prometheus.Metric, documents map[string]Document) {
+	counts := make(map[UserRef]int)
+	for _, document := range documents {
+		if document.CreatedBy.ID != "" {
+			counts[document.CreatedBy]++
+		}
+	}
+	for user, count := range counts {
+		ch <- prometheus.MustNewConstMetric(e.documentsCreatedBy, prometheus.GaugeValue, float64(count), user.ID, user.Name)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Set at build time with -ldflags "-X main.version=... -X main.commit=...
+// -X main.buildDate=...".
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// buildCommit returns the commit the binary was built from, falling back to
+// the revision Go records for builds inside a git checkout.
+func buildCommit() string {
+	if commit != "" {
+		return commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+func versionString() string {
+	return fmt.Sprintf("outline-exporter %s (commit %s, built %s, %s)", version, buildCommit(), buildDate, runtime.Version())
+}
+
+func newBuildInfo() prometheus.Collector {
+	info := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "outline_exporter_build_info",
+		Help: "Version of the exporter build, always 1",
+		ConstLabels: prometheus.Labels{
+			"version":    version,
+			"commit":     buildCommit(),
+			"build_date": buildDate,
+			"goversion":  runtime.Version(),
+		},
+	})
+	info.Set(1)
+	return info
+}
@@ -0,0 +1,71 @@
+package main
+
+import "time"
+
+// trackViews compares view counts between two snapshots. It remembers when
+// the view count of each document last went up, since Outline doesn't
+// report when a document was last viewed, and maintains an exponentially
+// weighted moving average of views per hour for documents and collections.
+// Must be called with e.mu held.
+func (e *Exporter) trackViews(previous, current *snapshot) {
+	views := make(map[string]int, len(previous.documents))
+	for _, document := range previous.documents {
+		views[document.ID] = document.Views
+	}
+
+	hours := current.takenAt.Sub(previous.takenAt).Hours()
+	lastViewed := make(map[string]time.Time, len(current.documents))
+	documentRates := make(map[string]float64, len(current.documents))
+	collectionDeltas := make(map[string]int)
+	for _, document := range current.documents {
+		before, known := views[document.ID]
+		if known && document.Views > before {
+			lastViewed[document.ID] = current.takenAt
+		} else if seen, ok := e.lastViewed[document.ID]; ok {
+			lastViewed[document.ID] = seen
+		}
+
+		if !known || hours <= 0 {
+			continue
+		}
+		delta := max(document.Views-before, 0)
+		collectionDeltas[document.CollectionId] += delta
+		documentRates[document.ID] = e.smooth(e.documentViewsRate, document.ID, float64(delta)/hours)
+	}
+
+	collectionRates := make(map[string]float64, len(collectionDeltas))
+	if hours > 0 {
+		for collectionID, delta := range collectionDeltas {
+			collectionRates[collectionID] = e.smooth(e.collectionViewsRate, collectionID, float64(delta)/hours)
+		}
+	}
+
+	e.lastViewed = lastViewed
+	e.documentViewsRate = documentRates
+	e.collectionViewsRate = collectionRates
+}
+
+// smooth folds a new observation into the moving average stored under key.
+// The first observation seeds the average.
+func (e *Exporter) smooth(averages map[string]float64, key string, value float64) float64 {
+	average, ok := averages[key]
+	if !ok {
+		return value
+	}
+	alpha := e.config.ViewsRateAlpha
+	return alpha*value + (1-alpha)*average
+}
+
+func (e *Exporter) collectionViewsRateFor(collectionID string) (float64, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	rate, ok := e.collectionViewsRate[collectionID]
+	return rate, ok
+}
+
+func (e *Exporter) documentViewsRateFor(documentID string) (float64, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	rate, ok := e.documentViewsRate[documentID]
+	return rate, ok
+}